@@ -0,0 +1,146 @@
+package wordwrap
+
+import (
+	"sort"
+	"strings"
+)
+
+// canBalance reports whether balancedBreaks may lay out items. It requires the
+// width of a line to be the sum of the widths of its items and their gaps, so
+// the cost of a line is the same function of its width wherever it begins:
+// there's no continuation indent, and no gap holds a tab.
+func (s *Scanner) canBalance(items []item) bool {
+	if s.indent != 0 {
+		return false
+	}
+	for _, it := range items {
+		if strings.IndexByte(it.gap, '\t') >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// balancedBreaks returns the same breaks as dynamicBreaks in O(n log n) time,
+// rather than time proportional to n times the number of items which fit on a
+// line, which grows with the limit.
+//
+// Where items' widths add up, the cost of a line satisfies the quadrangle
+// inequality: for breaks a < b, once breaking after b leads to a cheaper line
+// ending before some item than breaking after a, it does for every later item
+// too. Each candidate break is thus the best for a contiguous run of items, and
+// a queue of candidates with the item at which each takes over is kept, finding
+// where a new candidate takes over by binary search.
+func (s *Scanner) balancedBreaks(items []item, limit int) []int {
+	n := len(items)
+	if n == 0 {
+		return []int{0}
+	}
+
+	// sum[k] is the width of the items before k along with their gaps, and
+	// nextForced[k] the index of the first forced item after k, or n.
+	sum := make([]int, n+1)
+	gaps := make([]int, n)
+	for k, it := range items {
+		gaps[k] = s.gapWidth(it.gap, 0)
+		sum[k+1] = sum[k] + gaps[k] + it.width
+	}
+	nextForced := make([]int, n)
+	for k, next := n-1, n; k >= 0; k-- {
+		nextForced[k] = next
+		if items[k].forced {
+			next = k
+		}
+	}
+	lead := s.leadWidth(items[0], true, limit) - items[0].width
+
+	cost := make([]int, n+1)
+	prev := make([]int, n+1)
+
+	// lineCost returns the cost of the best breaks up to item i followed by a
+	// line of items i to j, or false if that line doesn't fit.
+	lineCost := func(i, j int) (int, bool) {
+		width := sum[j] - sum[i] - gaps[i]
+		if i == 0 {
+			width += lead
+		}
+		if j > i+1 && (nextForced[i] < j || width > limit) {
+			return 0, false
+		}
+		c := cost[i]
+		if j < n {
+			c += (limit - width) * (limit - width)
+			if items[j].glued {
+				c += gluePenalty
+			}
+		}
+		return c, true
+	}
+
+	// beats reports whether a line beginning at item b, after a, is better than
+	// one beginning at a for a line ending before item j. The earlier break
+	// wins ties, as in dynamicBreaks.
+	beats := func(b, a, j int) bool {
+		cb, okB := lineCost(b, j)
+		ca, okA := lineCost(a, j)
+		return !okA || okB && cb < ca
+	}
+
+	type candidate struct {
+		i, from int // Line start, and first item before which it's the best break.
+	}
+	var queue []candidate
+	head := 0
+	for j := 1; j <= n; j++ {
+		// Item j-1 becomes a candidate, superseding those it beats everywhere
+		// they were best.
+		i := j - 1
+		for len(queue) > head {
+			last := queue[len(queue)-1]
+			if !beats(i, last.i, maxInt(last.from, j)) {
+				break
+			}
+			queue = queue[:len(queue)-1]
+		}
+		if len(queue) == head {
+			queue = append(queue, candidate{i, j})
+		} else {
+			last := queue[len(queue)-1]
+			lo := maxInt(last.from, j) + 1
+			from := lo + sort.Search(n+1-lo, func(d int) bool { return beats(i, last.i, lo+d) })
+			if from <= n {
+				queue = append(queue, candidate{i, from})
+			}
+		}
+
+		for len(queue)-head > 1 && queue[head+1].from <= j {
+			head++
+		}
+		prev[j] = queue[head].i
+		cost[j], _ = lineCost(prev[j], j)
+	}
+	return breakStarts(prev)
+}
+
+// breakStarts returns the index of the first item on each line given, for each
+// item count j, the start of the last line of the best layout of j items.
+func breakStarts(prev []int) []int {
+	var starts []int
+	for j := len(prev) - 1; j > 0; j = prev[j] {
+		starts = append(starts, prev[j])
+	}
+	for l, r := 0, len(starts)-1; l < r; l, r = l+1, r-1 {
+		starts[l], starts[r] = starts[r], starts[l]
+	}
+	if len(starts) == 0 {
+		starts = []int{0}
+	}
+	return starts
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,140 @@
+package wordwrap
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomItems returns n items of random width separated by random gaps, with
+// some forced to begin a line as pieces of long words are.
+func randomItems(r *rand.Rand, n int) []item {
+	items := make([]item, n)
+	for k := range items {
+		width := 1 + r.Intn(6)
+		items[k] = item{
+			gap:    strings.Repeat(" ", r.Intn(3)),
+			text:   strings.Repeat("x", width),
+			width:  width,
+			forced: k > 0 && r.Intn(8) == 0,
+		}
+	}
+	return items
+}
+
+// raggedCost returns the cost of laying out items with lines beginning at the
+// given indices, as minRaggedBreaks measures it, or false if a line of more
+// than one item doesn't fit.
+func raggedCost(s *Scanner, items []item, starts []int, limit int) (int, bool) {
+	cost := 0
+	for n, start := range starts {
+		end := len(items)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		width := s.leadWidth(items[start], start == 0, limit)
+		for _, it := range items[start+1 : end] {
+			if it.forced {
+				return 0, false
+			}
+			width += s.gapWidth(it.gap, width) + it.width
+		}
+		if end > start+1 && width > limit {
+			return 0, false
+		}
+		if end < len(items) {
+			cost += (limit - width) * (limit - width)
+		}
+	}
+	return cost, true
+}
+
+// bruteForceCost returns the least cost of any layout of items, trying every
+// combination of breaks.
+func bruteForceCost(s *Scanner, items []item, limit int) int {
+	best := math.MaxInt
+	for mask := 0; mask < 1<<(len(items)-1); mask++ {
+		starts := []int{0}
+		for k := 1; k < len(items); k++ {
+			if mask&(1<<(k-1)) != 0 {
+				starts = append(starts, k)
+			}
+		}
+		if cost, ok := raggedCost(s, items, starts, limit); ok && cost < best {
+			best = cost
+		}
+	}
+	return best
+}
+
+func TestBalancedBreaks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := NewScanner(strings.NewReader(""), 1)
+	for round := 0; round < 2000; round++ {
+		items := randomItems(r, 1+r.Intn(12))
+		limit := 4 + r.Intn(12)
+
+		got := s.balancedBreaks(items, limit)
+		assert.Equal(t, s.dynamicBreaks(items, limit), got, "Breaks should match the dynamic program for %v at %d.", items, limit)
+		cost, ok := raggedCost(s, items, got, limit)
+		if assert.True(t, ok, "Every line should fit.") {
+			assert.Equal(t, bruteForceCost(s, items, limit), cost, "Breaks should be optimal for %v at %d.", items, limit)
+		}
+	}
+}
+
+func TestCanBalance(t *testing.T) {
+	s := NewScanner(strings.NewReader(""), 10)
+	assert.True(t, s.canBalance([]item{{text: "a"}, {gap: "  ", text: "b"}}))
+	assert.False(t, s.canBalance([]item{{text: "a"}, {gap: "\t", text: "b"}}), "Tabs should be measured where they begin.")
+	s.indent = 2
+	assert.False(t, s.canBalance([]item{{text: "a"}}), "A continuation indent should be measured.")
+}
+
+var benchText = strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200)
+
+// benchItems returns a long paragraph of items, as benchText would be split.
+func benchItems(n int) []item {
+	items := randomItems(rand.New(rand.NewSource(1)), n)
+	for k := range items {
+		items[k].gap, items[k].forced = " ", false
+	}
+	return items
+}
+
+func BenchmarkBalancedBreaks(b *testing.B) {
+	items := benchItems(20000)
+	s := NewScanner(strings.NewReader(""), 1)
+	for _, limit := range []int{80, 2000} {
+		b.Run(strconv.Itoa(limit), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.balancedBreaks(items, limit)
+			}
+		})
+	}
+}
+
+func BenchmarkDynamicBreaks(b *testing.B) {
+	items := benchItems(20000)
+	s := NewScanner(strings.NewReader(""), 1)
+	for _, limit := range []int{80, 2000} {
+		b.Run(strconv.Itoa(limit), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.dynamicBreaks(items, limit)
+			}
+		})
+	}
+}
+
+func BenchmarkMinRagged(b *testing.B) {
+	text := strings.Repeat(benchText, 10)
+	for i := 0; i < b.N; i++ {
+		s := NewScanner(strings.NewReader(text), 2000)
+		s.SetBreakPreference(MinRagged)
+		s.Drain()
+	}
+}
@@ -0,0 +1,115 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ProposedBreak describes a line break which a Scanner proposes to make before
+// a word which doesn't fit within the limit. See SetBreakHook.
+type ProposedBreak struct {
+	Line   string // Text of the line so far, without its prefix or indent.
+	Word   string // Word which would begin the next line.
+	Column int    // Width of the line so far, including its indent.
+}
+
+// vetoesBreak reports whether the break hook rejects breaking a line before the
+// given word. The hook must be set.
+func (s *Scanner) vetoesBreak(line, word string, col int) bool {
+	return !s.cfg.BreakHook(ProposedBreak{Line: line, Word: word, Column: col})
+}
+
+// runText returns the text of items laid out on a line. The first item's gap is
+// kept only if it begins the paragraph and fits, as with leadWidth.
+func (s *Scanner) runText(items []item, first bool, limit int) string {
+	var b strings.Builder
+	col := 0
+	if !first {
+		col = s.indent
+	}
+	for i, it := range items {
+		if i > 0 || s.leadWidth(it, first, limit) > it.width {
+			gap, width := s.expandGap(it.gap, col)
+			b.WriteString(gap)
+			col += width
+		}
+		b.WriteString(it.text)
+		col += it.width
+	}
+	return b.String()
+}
+
+// WrapWithBreaks wraps runes into lines of at most limit columns, breaking
+// only where the caller permits. This allows break opportunities to be computed
+// separately, such as by a language-aware analyzer, and used for layout.
+//
+// breakable[i] reports whether a line may break before runes[i]; missing
+// entries are treated as false. Lines are broken at the last permitted break
+// which fits, or at the limit if none does. Newlines always break a line.
+// Whitespace is trimmed from the end of each line and from the start of lines
+// following a break, but is otherwise ignored when choosing breaks. Widths are
+// measured as by a Scanner with the default configuration, though a character
+// wider than the limit is placed on a line of its own. Lines never break within
+// a grapheme cluster, so combining marks stay with the character they follow
+// and emoji sequences stay whole.
+func WrapWithBreaks(runes []rune, breakable []bool, limit int) []string {
+	if limit < 1 {
+		limit = 1
+	}
+
+	// Runes continuing a grapheme cluster never begin a line.
+	var cl cluster
+	joined := make([]bool, len(runes))
+	for i, r := range runes {
+		joined[i] = !cl.breaksBefore(r) && i > 0
+	}
+
+	var cfg Config
+	var lines []string
+	start, lastBreak, width := 0, -1, 0
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		if char == '\n' {
+			lines = append(lines, trimRunes(runes[start:i]))
+			start, lastBreak, width = i+1, -1, 0
+			continue
+		}
+
+		canBreak := i > start && i < len(breakable) && breakable[i] && !joined[i]
+		w := 0
+		if !joined[i] || isRegionalIndicator(char) {
+			w = cfg.runeWidth(char)
+		}
+		if unicode.IsSpace(char) || joined[i] || width+w <= limit || i == start {
+			if canBreak {
+				lastBreak = i
+			}
+			width += w
+			continue
+		}
+
+		// The rune doesn't fit, so break the line.
+		end := i
+		if !canBreak && lastBreak > start {
+			end = lastBreak
+		}
+		lines = append(lines, trimRunes(runes[start:end]))
+
+		// Skip leading whitespace on the continuation, then resume from there.
+		for end < len(runes) && runes[end] != '\n' && unicode.IsSpace(runes[end]) {
+			end++
+		}
+		start, lastBreak, width = end, -1, 0
+		i = end - 1
+	}
+	return append(lines, trimRunes(runes[start:]))
+}
+
+// trimRunes converts runes to a string with trailing whitespace removed.
+func trimRunes(runes []rune) string {
+	end := len(runes)
+	for end > 0 && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	return string(runes[:end])
+}
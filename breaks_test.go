@@ -0,0 +1,98 @@
+package wordwrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// breaksAt builds a mask for text permitting breaks only at the given indices.
+func breaksAt(text string, indices ...int) ([]rune, []bool) {
+	runes := []rune(text)
+	mask := make([]bool, len(runes))
+	for _, i := range indices {
+		mask[i] = true
+	}
+	return runes, mask
+}
+
+func TestWrapWithBreaks(t *testing.T) {
+	cases := []struct {
+		message  string
+		text     string
+		breaks   []int
+		width    int
+		expected []string
+	}{
+		{
+			"Lines should break at the last permitted break which fits.",
+			"aaa-bbb-ccc", []int{4, 8}, 8,
+			[]string{"aaa-bbb-", "ccc"},
+		},
+		{
+			"Every permitted break may be used.",
+			"aaa-bbb-ccc", []int{4, 8}, 6,
+			[]string{"aaa-", "bbb-", "ccc"},
+		},
+		{
+			"Spaces should not be break opportunities unless permitted.",
+			"ab cd ef", []int{6}, 5,
+			[]string{"ab cd", "ef"},
+		},
+		{
+			"Lines should be force-broken when no break fits.",
+			"ab cd ef", []int{6}, 4,
+			[]string{"ab c", "d ef"},
+		},
+		{
+			"Emoji joined into a family should take the width of one.",
+			"a👨\u200d👩\u200d👧b", nil, 3,
+			[]string{"a👨\u200d👩\u200d👧", "b"},
+		},
+		{
+			"Lines should not break within a grapheme cluster, even where permitted.",
+			"a👨\u200d👩\u200d👧b", []int{3}, 2,
+			[]string{"a", "👨\u200d👩\u200d👧", "b"},
+		},
+		{
+			"Newlines should always break.",
+			"ab\ncd", nil, 8,
+			[]string{"ab", "cd"},
+		},
+		{
+			"Wide characters should count as two columns.",
+			"日本語日本語", nil, 4,
+			[]string{"日本", "語日", "本語"},
+		},
+		{
+			"Wide characters should break at permitted breaks.",
+			"日本語日本語", []int{3}, 6,
+			[]string{"日本語", "日本語"},
+		},
+		{
+			"A character wider than the limit should overflow it.",
+			"日本", nil, 1,
+			[]string{"日", "本"},
+		},
+		{
+			"Combining marks should not be orphaned.",
+			"abe\u0301cd", nil, 3,
+			[]string{"abe\u0301", "cd"},
+		},
+		{
+			"Spacing marks should overflow rather than be orphaned.",
+			"\u0915\u093F\u0915", nil, 1,
+			[]string{"\u0915\u093F", "\u0915"},
+		},
+		{
+			"Empty input should produce one empty line.",
+			"", nil, 4,
+			[]string{""},
+		},
+	}
+
+	for _, c := range cases {
+		runes, mask := breaksAt(c.text, c.breaks...)
+		assert.Equal(t, c.expected, WrapWithBreaks(runes, mask, c.width), c.message)
+	}
+}
@@ -0,0 +1,53 @@
+package wordwrap
+
+import "strings"
+
+// bulletMarkers lists the words which mark list items, as recognized by
+// SetDetectBullets, besides numbers.
+var bulletMarkers = []string{"-", "*", "+", "•", "◦", "‣", "⁃"}
+
+// isBullet reports whether word is a bullet marker: one of bulletMarkers, or a
+// number of up to three digits followed by "." or ")".
+func isBullet(word string) bool {
+	for _, m := range bulletMarkers {
+		if word == m {
+			return true
+		}
+	}
+
+	n := strings.TrimRight(word[:len(word)-1], "0123456789")
+	digits := len(word) - 1 - len(n)
+	end := word[len(word)-1]
+	return n == "" && digits > 0 && digits <= 3 && (end == '.' || end == ')')
+}
+
+// alignBullet sets the continuation indent for the current line of input if it
+// begins with a bullet marker and the item is the word following it. The
+// indent is the column at which the item begins, provided it fits there.
+func (s *Scanner) alignBullet(it item) {
+	if !s.bullet || s.lineWords != 2 || s.aligned {
+		return
+	}
+
+	s.aligned = true
+	col := s.lineWidth + s.gapWidth(it.gap, s.lineWidth)
+	if col+it.width <= s.textLimit() {
+		s.indent = col
+	}
+}
+
+// bulletIndent returns the continuation indent for a line of input beginning
+// with a bullet marker and laid out as a whole, along with the index of the
+// item following the marker. The indent is the column at which that item
+// begins, or zero if it doesn't fit there.
+func (s *Scanner) bulletIndent(items []item, limit int) (int, int) {
+	if len(items) < 2 {
+		return 0, len(items)
+	}
+	col := s.gapWidth(items[0].gap, 0) + items[0].width
+	col += s.gapWidth(items[1].gap, col)
+	if col+items[1].width > limit {
+		return 0, 1
+	}
+	return col, 1
+}
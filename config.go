@@ -0,0 +1,447 @@
+package wordwrap
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// DefaultTabWidth is the tab width used by NewScanner.
+const DefaultTabWidth = 4
+
+// ErrStreaming is returned when an option which buffers whole lines of input is
+// set along with streaming. See SetStreaming.
+var ErrStreaming = errors.New("wordwrap: option requires buffering, which streaming disables")
+
+// ErrOutputLimit is returned by WriteTo when its output reaches the byte limit
+// set with SetMaxOutputBytes before the text ends.
+var ErrOutputLimit = errors.New("wordwrap: output reached the byte limit")
+
+// ErrLineTooWide is wrapped by the *LineWidthError returned for a line wider
+// than the limit when SetStrictWidth is enabled.
+var ErrLineTooWide = errors.New("wordwrap: line exceeds the limit")
+
+// ErrPrefixNewline is returned for a prefix containing a line break, which would
+// split each line it's applied to. See SetPrefixErr.
+var ErrPrefixNewline = errors.New("wordwrap: prefix must not contain a line break")
+
+// Config holds every option accepted by a Scanner. It allows a Scanner to be
+// built from declarative settings, such as those loaded from a configuration
+// file, rather than a sequence of setter calls.
+type Config struct {
+	// Limit is the maximum number of columns per line, excluding the prefix.
+	Limit int `json:"limit"`
+
+	// Prefix is applied to each non-empty line. See SetPrefix.
+	Prefix string `json:"prefix"`
+
+	// PrefixFunc computes a prefix for each line, overriding Prefix when
+	// non-nil. See SetPrefixFunc.
+	PrefixFunc func(line int) string `json:"-"`
+
+	// PrefixOnBlankLines applies the prefix to empty lines. See
+	// SetPrefixOnBlankLines.
+	PrefixOnBlankLines bool `json:"prefixOnBlankLines"`
+
+	// TrimPrefixOnBlank trims trailing whitespace from the prefix on empty
+	// lines. See SetTrimPrefixOnBlank.
+	TrimPrefixOnBlank bool `json:"trimPrefixOnBlank"`
+
+	// PrefixCountsTowardLimit deducts the prefix's display width from Limit
+	// when true. See SetPrefixCountsTowardLimit.
+	PrefixCountsTowardLimit bool `json:"prefixCountsTowardLimit"`
+
+	// GutterSeparator is placed between the prefix and text of each non-empty
+	// line. See SetGutterSeparator.
+	GutterSeparator string `json:"gutterSeparator"`
+
+	// GutterSeparatorCountsTowardLimit deducts the separator's display width
+	// from Limit when true.
+	GutterSeparatorCountsTowardLimit bool `json:"gutterSeparatorCountsTowardLimit"`
+
+	// KeepTrailingSpace keeps whitespace at the end of each line of input. See
+	// SetTrimTrailingSpace.
+	KeepTrailingSpace bool `json:"keepTrailingSpace"`
+
+	// TrailingTrim holds runes removed from the end of each line along with
+	// trailing whitespace. See SetTrailingTrim.
+	TrailingTrim string `json:"trailingTrim"`
+
+	// TrimLeadingAfterNewline discards whitespace at the start of each line of
+	// input following a newline. See SetTrimLeadingAfterNewline.
+	TrimLeadingAfterNewline bool `json:"trimLeadingAfterNewline"`
+
+	// NormalizeNewlines reads all common newline conventions as "\n". See
+	// SetNormalizeNewlines.
+	NormalizeNewlines bool `json:"normalizeNewlines"`
+
+	// NormalizeFormFeed also reads form feeds as "\n" when NormalizeNewlines
+	// is set.
+	NormalizeFormFeed bool `json:"normalizeFormFeed"`
+
+	// Reflow joins lines within a paragraph before wrapping. See SetReflow.
+	Reflow bool `json:"reflow"`
+
+	// Dehyphenate rejoins words hyphenated across lines when reflowing. See
+	// SetDehyphenate.
+	Dehyphenate bool `json:"dehyphenate"`
+
+	// DetectBullets indents lines continuing a list item to follow its bullet
+	// marker. See SetDetectBullets.
+	DetectBullets bool `json:"detectBullets"`
+
+	// LineMode wraps each line of input on its own, overriding options which
+	// join or add lines. See SetLineMode.
+	LineMode bool `json:"lineMode"`
+
+	// TabWidth is the width of tab characters. Zero selects DefaultTabWidth,
+	// as with NewScanner, and a negative value removes tabs from the output,
+	// as with SetTabWidth(0). See SetTabWidth.
+	TabWidth int `json:"tabWidth"`
+
+	// TabStops lists explicit tab stops, used before falling back to TabWidth.
+	// See SetTabStops.
+	TabStops []int `json:"tabStops"`
+
+	// TabFixedWidth makes every tab as wide as TabWidth, wherever it begins.
+	// See SetTabFixedWidth.
+	TabFixedWidth bool `json:"tabFixedWidth"`
+
+	// TabExpand computes the column following a tab, overriding TabStops and
+	// TabWidth when non-nil. See SetTabExpandToColumnFunc.
+	TabExpand func(col int) int `json:"-"`
+
+	// TabFill renders the expansion of a tab, in place of spaces, when non-nil.
+	// See SetTabFillFunc.
+	TabFill func(width int) string `json:"-"`
+
+	// ControlCharMode determines how control characters are rendered. See
+	// SetControlCharMode.
+	ControlCharMode ControlCharMode `json:"controlCharMode"`
+
+	// BreakPreference selects the heuristic used to choose line breaks. See
+	// SetBreakPreference.
+	BreakPreference BreakPreference `json:"breakPreference"`
+
+	// MinWordsPerLine is the minimum number of words preferred on each line
+	// but the last of a paragraph. See SetMinWordsPerLine.
+	MinWordsPerLine int `json:"minWordsPerLine"`
+
+	// ReplaceNBSPWithSpace emits no-break spaces as regular spaces. See
+	// SetReplaceNBSPWithSpace.
+	ReplaceNBSPWithSpace bool `json:"replaceNBSPWithSpace"`
+
+	// GapChar renders whitespace which differs from the input, such as
+	// expanded tabs and newlines joined by reflowing, when non-zero. See
+	// SetGapChar.
+	GapChar rune `json:"gapChar"`
+
+	// MaskChar replaces each rune of output other than whitespace when
+	// non-zero. See SetMaskChar.
+	MaskChar rune `json:"maskChar"`
+
+	// Replacer rewrites each word before it's measured, when non-nil. See
+	// SetReplacer.
+	Replacer *strings.Replacer `json:"-"`
+
+	// BreakHook accepts or rejects each line break proposed for a word which
+	// doesn't fit, when non-nil. See SetBreakHook.
+	BreakHook func(d ProposedBreak) bool `json:"-"`
+
+	// OnLimitChange is called when a line is laid out at a different limit
+	// than the line before it, when non-nil. See SetOnLimitChange.
+	OnLimitChange func(old, new int) `json:"-"`
+
+	// ErrorHandler decides whether a failed read is retried. See
+	// SetErrorHandler.
+	ErrorHandler func(err error) error `json:"-"`
+
+	// AmbiguousWidth is the width of East Asian ambiguous characters, either 1
+	// or 2. Zero is treated as 1. See SetAmbiguousWidth.
+	AmbiguousWidth int `json:"ambiguousWidth"`
+
+	// AlignContinuationToTab indents continuation lines to follow the first
+	// tab of a line. See SetAlignContinuationToTab.
+	AlignContinuationToTab bool `json:"alignContinuationToTab"`
+
+	// TabJoinsWords keeps words separated only by tabs on one line. See
+	// SetTabIsWordBreak, which sets the inverse.
+	TabJoinsWords bool `json:"tabJoinsWords"`
+
+	// Directives enables alignment directives in the text. See SetDirectives.
+	Directives bool `json:"directives"`
+
+	// Streaming rejects options which buffer whole lines of input. See
+	// SetStreaming.
+	Streaming bool `json:"streaming"`
+
+	// MaxLines is the maximum number of lines returned, if positive. See
+	// SetMaxLines.
+	MaxLines int `json:"maxLines"`
+
+	// Ellipsis marks the last line returned when lines are dropped. See
+	// SetEllipsis.
+	Ellipsis string `json:"ellipsis"`
+
+	// TruncationSuffix computes the suffix marking the last line returned,
+	// overriding Ellipsis when non-nil. See SetTruncationSuffix.
+	TruncationSuffix func(dropped int) string `json:"-"`
+
+	// UnicodeLineBreaks allows lines to break within East Asian text. See
+	// SetUnicodeLineBreaks.
+	UnicodeLineBreaks bool `json:"unicodeLineBreaks"`
+
+	// Locale is a BCP 47 tag selecting tailored line breaking rules. See
+	// SetLocale.
+	Locale string `json:"locale"`
+
+	// DebugWhitespace makes whitespace visible in the output. See
+	// SetDebugWhitespace.
+	DebugWhitespace bool `json:"debugWhitespace"`
+
+	// MinParagraphGap is the minimum number of blank lines between
+	// paragraphs. See SetMinParagraphGap.
+	MinParagraphGap int `json:"minParagraphGap"`
+
+	// PreferSentenceBreaks breaks lines after the end of a sentence where
+	// possible. See SetPreferSentenceBreaks.
+	PreferSentenceBreaks bool `json:"preferSentenceBreaks"`
+
+	// PrefixPlacement places the prefix on aligned lines. See
+	// SetPrefixPlacement.
+	PrefixPlacement PrefixPlacement `json:"prefixPlacement"`
+
+	// MergePrefixPadding merges whitespace ending the prefix into the padding
+	// of aligned lines. See SetMergePrefixPadding.
+	MergePrefixPadding bool `json:"mergePrefixPadding"`
+
+	// AlignPrefixToTab pads the prefix to a tab stop. See SetAlignPrefixToTab.
+	AlignPrefixToTab bool `json:"alignPrefixToTab"`
+
+	// BreakAnywhere allows lines to break between any two characters. See
+	// SetBreakAnywhere.
+	BreakAnywhere bool `json:"breakAnywhere"`
+
+	// Verbatim keeps all whitespace, breaking lines only where they reach the
+	// limit. See SetVerbatim.
+	Verbatim bool `json:"verbatim"`
+
+	// RightMargin is the number of columns reserved at the end of each line.
+	// See SetRightMargin.
+	RightMargin int `json:"rightMargin"`
+
+	// AlignIntoRightMargin aligns lines within the limit including the right
+	// margin. See SetAlignIntoRightMargin.
+	AlignIntoRightMargin bool `json:"alignIntoRightMargin"`
+
+	// KeepNumbers keeps numbers whole rather than breaking them to fit. See
+	// SetKeepNumbers.
+	KeepNumbers bool `json:"keepNumbers"`
+
+	// NoLeadingFragment keeps words which fit within the limit whole, so no
+	// line begins with the rest of a broken word unless the word is wider than
+	// the limit. See SetNoLeadingFragment.
+	NoLeadingFragment bool `json:"noLeadingFragment"`
+
+	// QuoteAware keeps quoted spans whole. See SetQuoteAware.
+	QuoteAware bool `json:"quoteAware"`
+
+	// StrictWidth reports lines wider than the limit as errors. See
+	// SetStrictWidth.
+	StrictWidth bool `json:"strictWidth"`
+
+	// Hyphenator returns the points at which a word may be hyphenated when
+	// it must be broken, when non-nil. See SetHyphenator.
+	Hyphenator func(word string) []int `json:"-"`
+
+	// HyphenationMinWordLen is the fewest runes a word must have to be broken
+	// when it's too wide for a line. See SetHyphenationMinWordLen.
+	HyphenationMinWordLen int `json:"hyphenationMinWordLen"`
+
+	// KeepDigitGroups keeps runs of digits together when breaking words. See
+	// SetKeepDigitGroups.
+	KeepDigitGroups bool `json:"keepDigitGroups"`
+
+	// OverflowLimit caps the width of each line returned, and
+	// OverflowEllipsis marks lines cut short by it. See SetTruncateOverflow.
+	OverflowLimit    int    `json:"overflowLimit"`
+	OverflowEllipsis string `json:"overflowEllipsis"`
+
+	// ColumnGuides pads each line to the limit. See SetColumnGuides.
+	ColumnGuides bool `json:"columnGuides"`
+
+	// PadBlankLines pads blank lines along with ColumnGuides. See
+	// SetPadBlankLines.
+	PadBlankLines bool `json:"padBlankLines"`
+
+	// FillColumn, if positive, is the column to which FillRune pads the text
+	// of each line. See SetFillToColumn.
+	FillColumn int  `json:"fillColumn"`
+	FillRune   rune `json:"fillRune"`
+
+	// BreakOnUnderscore allows lines to break after underscores within words.
+	// See SetBreakOnUnderscore.
+	BreakOnUnderscore bool `json:"breakOnUnderscore"`
+
+	// LastLine selects the lines marked as last, which take LastLinePrefix in
+	// place of the prefix and end with LastLineSuffix. See SetLastLineMode.
+	LastLine       LastLineMode `json:"lastLine"`
+	LastLinePrefix string       `json:"lastLinePrefix"`
+	LastLineSuffix string       `json:"lastLineSuffix"`
+
+	// MaxOutputBytes is the maximum number of bytes WriteTo writes, if
+	// positive. See SetMaxOutputBytes.
+	MaxOutputBytes int64 `json:"maxOutputBytes"`
+
+	// HTMLOutput makes WriteTo escape its output as HTML text, separating lines
+	// with HTMLLineBreak, or "<br>\n" if it's empty. See SetHTMLOutput.
+	HTMLOutput    bool   `json:"htmlOutput"`
+	HTMLLineBreak string `json:"htmlLineBreak"`
+
+	// CollectStats counts the work done by a Scanner. See SetCollectStats.
+	CollectStats bool `json:"collectStats"`
+
+	stats *Stats // Counts width lookups while CollectStats is set.
+}
+
+// Validate reports whether the configuration describes a usable Scanner.
+func (c Config) Validate() error {
+	switch {
+	case c.Limit < 1:
+		return errors.New("wordwrap: limit must be positive")
+	case c.ControlCharMode < ControlCharPass || c.ControlCharMode > ControlCharCaret:
+		return errors.New("wordwrap: unknown control character mode")
+	case c.BreakPreference < MaxFit || c.BreakPreference > BreakBeforeShort:
+		return errors.New("wordwrap: unknown break preference")
+	case c.PrefixPlacement < PrefixMargin || c.PrefixPlacement > PrefixHug:
+		return errors.New("wordwrap: unknown prefix placement")
+	case !validTabStops(c.TabStops):
+		return errors.New("wordwrap: tab stops must be positive and increasing")
+	case c.AmbiguousWidth < 0 || c.AmbiguousWidth > 2:
+		return errors.New("wordwrap: ambiguous width must be 1 or 2")
+	case c.validPrefix(c.Prefix) != nil:
+		return c.validPrefix(c.Prefix)
+	case c.GutterSeparatorCountsTowardLimit && c.stringWidth(c.GutterSeparator) >= c.Limit:
+		return errors.New("wordwrap: gutter separator must be shorter than limit")
+	case c.RightMargin < 0 || c.RightMargin >= c.Limit:
+		return errors.New("wordwrap: right margin must be narrower than limit")
+	case c.FillColumn < 0:
+		return errors.New("wordwrap: fill column must not be negative")
+	case c.GapChar != 0 && c.runeWidth(c.GapChar) != 1:
+		return errors.New("wordwrap: gap character must be one column wide")
+	case c.OverflowLimit < 0:
+		return errors.New("wordwrap: overflow limit must not be negative")
+	case c.MaxOutputBytes < 0:
+		return errors.New("wordwrap: max output bytes must not be negative")
+	case c.HyphenationMinWordLen < 0:
+		return errors.New("wordwrap: hyphenation minimum word length must not be negative")
+	case c.LastLine < LastLineNone || c.LastLine > LastLineOfParagraph:
+		return errors.New("wordwrap: unknown last line mode")
+	case c.Streaming && c.buffers():
+		return ErrStreaming
+	}
+	return nil
+}
+
+// buffers reports whether the configuration lays out whole lines of input at
+// once, so must buffer each until its end.
+func (c *Config) buffers() bool {
+	return c.BreakPreference != MaxFit || c.MinWordsPerLine > 1 || c.TabJoinsWords || c.PreferSentenceBreaks
+}
+
+// tabWidth returns the width of tab characters, resolving TabWidth's defaults.
+func (c *Config) tabWidth() int {
+	switch {
+	case c.TabWidth == 0:
+		return DefaultTabWidth
+	case c.TabWidth < 0:
+		return 0
+	}
+	return c.TabWidth
+}
+
+// clone returns a copy of the configuration which shares no memory with it.
+func (c Config) clone() Config {
+	c.TabStops = append([]int(nil), c.TabStops...)
+	c.stats = nil
+	return c
+}
+
+// validTabStops reports whether stops are positive and strictly increasing.
+func validTabStops(stops []int) bool {
+	prev := 0
+	for _, stop := range stops {
+		if stop <= prev {
+			return false
+		}
+		prev = stop
+	}
+	return true
+}
+
+// ScannerFromConfig creates and initializes a new Scanner from the given reader
+// and configuration. It returns an error if the configuration is invalid. As
+// with NewScanner, the new Scanner takes ownership of the reader.
+func ScannerFromConfig(r io.Reader, cfg Config) (*Scanner, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return (&Wrapper{cfg: cfg}).NewScanner(r), nil
+}
+
+// tabAdvance returns the width of a tab beginning at the given column.
+func (c *Config) tabAdvance(col int) int {
+	if c.TabFixedWidth && c.TabExpand == nil {
+		return c.tabWidth()
+	}
+	return c.stopAdvance(col)
+}
+
+// stopAdvance returns the distance from the given column to the next tab stop.
+func (c *Config) stopAdvance(col int) int {
+	if c.TabExpand != nil {
+		if next := c.TabExpand(col); next > col {
+			return next - col
+		}
+		return 0
+	}
+	for _, stop := range c.TabStops {
+		if stop > col {
+			return stop - col
+		}
+	}
+	width := c.tabWidth()
+	if width == 0 {
+		return 0
+	}
+	return width - col%width
+}
+
+// tabFill renders the expansion of a tab of the given width.
+func (c *Config) tabFill(width int) string {
+	if c.TabFill != nil {
+		return c.TabFill(width)
+	}
+	return strings.Repeat(string(c.gapChar()), width)
+}
+
+// trimTrailing removes runes in TrailingTrim from the end of a line, along with
+// any whitespace they uncover unless trailing whitespace is kept.
+func (c *Config) trimTrailing(text string) string {
+	if c.KeepTrailingSpace {
+		return strings.TrimRight(text, c.TrailingTrim)
+	}
+	return strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(c.TrailingTrim, r)
+	})
+}
+
+// gapChar returns the rune rendering whitespace which differs from the input.
+func (c *Config) gapChar() rune {
+	if c.GapChar != 0 {
+		return c.GapChar
+	}
+	return ' '
+}
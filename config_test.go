@@ -0,0 +1,266 @@
+package wordwrap
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScannerFromConfig(t *testing.T) {
+	const text = "first\tline\nsecond line wraps"
+
+	cfg := Config{Limit: 10, Prefix: "> ", TabWidth: 8}
+	s, err := ScannerFromConfig(strings.NewReader(text), cfg)
+	require.NoError(t, err)
+
+	manual := NewScanner(strings.NewReader(text), 10)
+	manual.SetPrefix("> ")
+	manual.SetTabWidth(8)
+
+	var got, expected bytes.Buffer
+	_, err = s.WriteTo(&got)
+	require.NoError(t, err)
+	_, err = manual.WriteTo(&expected)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected.String(), got.String())
+	assert.Equal(t, "> first\n> line\n> second\n> line wraps", got.String())
+}
+
+func TestScannerFromFullConfig(t *testing.T) {
+	const text = "first\tline\ttabbed\r\nsecond line wr-\naps \u00A0 αβ\x01\n\n  third line"
+
+	prefixFunc := func(line int) string { return fmt.Sprintf("%d:", line) }
+	errorHandler := func(err error) error { return err }
+	truncationSuffix := func(n int) string { return fmt.Sprintf("+%d", n) }
+	cfg := Config{
+		Limit:                            14,
+		Prefix:                           "unused",
+		PrefixFunc:                       prefixFunc,
+		PrefixOnBlankLines:               true,
+		TrimPrefixOnBlank:                true,
+		GutterSeparator:                  "| ",
+		GutterSeparatorCountsTowardLimit: true,
+		KeepTrailingSpace:                true,
+		NormalizeNewlines:                true,
+		NormalizeFormFeed:                true,
+		Reflow:                           true,
+		Dehyphenate:                      true,
+		TabWidth:                         3,
+		TabStops:                         []int{7},
+		ControlCharMode:                  ControlCharCaret,
+		BreakPreference:                  MinRagged,
+		MinWordsPerLine:                  2,
+		ReplaceNBSPWithSpace:             true,
+		MaskChar:                         '#',
+		ErrorHandler:                     errorHandler,
+		AmbiguousWidth:                   2,
+		AlignContinuationToTab:           true,
+		TabJoinsWords:                    true,
+		Directives:                       true,
+		MaxLines:                         3,
+		Ellipsis:                         "~",
+		TruncationSuffix:                 truncationSuffix,
+		UnicodeLineBreaks:                true,
+		Locale:                           "ja",
+		DebugWhitespace:                  true,
+		MinParagraphGap:                  2,
+		PreferSentenceBreaks:             true,
+		PrefixPlacement:                  PrefixHug,
+		BreakAnywhere:                    true,
+		CollectStats:                     true,
+		RightMargin:                      1,
+		AlignIntoRightMargin:             true,
+		KeepNumbers:                      true,
+		OverflowLimit:                    40,
+		OverflowEllipsis:                 "…",
+		ColumnGuides:                     true,
+		PadBlankLines:                    true,
+		BreakOnUnderscore:                true,
+		LastLine:                         LastLineOfParagraph,
+		LastLinePrefix:                   "└ ",
+		LastLineSuffix:                   "$",
+		TabExpand:                        snapTo3,
+		TabFill:                          arrowFill,
+		TrimLeadingAfterNewline:          true,
+		LineMode:                         true,
+		AlignPrefixToTab:                 true,
+		KeepDigitGroups:                  true,
+		HTMLOutput:                       true,
+		HTMLLineBreak:                    "<br/>",
+		TabFixedWidth:                    true,
+		FillColumn:                       12,
+		FillRune:                         '.',
+		DetectBullets:                    true,
+		Verbatim:                         true,
+		BreakHook:                        rejectShortNext,
+		NoLeadingFragment:                true,
+		GapChar:                          '\u2009',
+		MaxOutputBytes:                   1 << 20,
+		MergePrefixPadding:               true,
+		Hyphenator:                       hyphenateAt(2),
+		TrailingTrim:                     ";",
+		QuoteAware:                       true,
+		HyphenationMinWordLen:            3,
+		StrictWidth:                      true,
+		PrefixCountsTowardLimit:          true,
+	}
+	s, err := ScannerFromConfig(strings.NewReader(text), cfg)
+	require.NoError(t, err)
+
+	manual := NewScanner(strings.NewReader(text), 14)
+	manual.SetPrefix("unused")
+	manual.SetPrefixFunc(prefixFunc)
+	manual.SetPrefixOnBlankLines(true)
+	manual.SetTrimPrefixOnBlank(true)
+	manual.SetGutterSeparator("| ", true)
+	manual.SetTrimTrailingSpace(false)
+	manual.SetNormalizeNewlines(true, true)
+	manual.SetReflow(true)
+	manual.SetDehyphenate(true)
+	manual.SetTabWidth(3)
+	manual.SetTabStops([]int{7})
+	manual.SetControlCharMode(ControlCharCaret)
+	manual.SetBreakPreference(MinRagged)
+	manual.SetMinWordsPerLine(2)
+	manual.SetReplaceNBSPWithSpace(true)
+	manual.SetMaskChar('#')
+	manual.SetErrorHandler(errorHandler)
+	manual.SetAmbiguousWidth(2)
+	manual.SetAlignContinuationToTab(true)
+	manual.SetTabIsWordBreak(false)
+	manual.SetDirectives(true)
+	manual.SetMaxLines(3)
+	manual.SetEllipsis("~")
+	manual.SetTruncationSuffix(truncationSuffix)
+	manual.SetUnicodeLineBreaks(true)
+	manual.SetLocale("ja")
+	manual.SetDebugWhitespace(true)
+	manual.SetMinParagraphGap(2)
+	manual.SetPreferSentenceBreaks(true)
+	manual.SetPrefixPlacement(PrefixHug)
+	manual.SetBreakAnywhere(true)
+	manual.SetCollectStats(true)
+	manual.SetRightMargin(1)
+	manual.SetAlignIntoRightMargin(true)
+	manual.SetKeepNumbers(true)
+	manual.SetTruncateOverflow(40, "…")
+	manual.SetColumnGuides(true)
+	manual.SetPadBlankLines(true)
+	manual.SetBreakOnUnderscore(true)
+	manual.SetLastLinePrefix("└ ")
+	manual.SetLastLineSuffix("$")
+	manual.SetLastLineMode(LastLineOfParagraph)
+	manual.SetTabExpandToColumnFunc(snapTo3)
+	manual.SetTabFillFunc(arrowFill)
+	manual.SetTrimLeadingAfterNewline(true)
+	manual.SetLineMode(true)
+	manual.SetAlignPrefixToTab(true)
+	manual.SetKeepDigitGroups(true)
+	manual.SetHTMLOutput(true)
+	manual.SetHTMLLineBreak("<br/>")
+	manual.SetTabFixedWidth(true)
+	manual.SetFillToColumn(12, '.')
+	manual.SetDetectBullets(true)
+	manual.SetVerbatim(true)
+	manual.SetBreakHook(rejectShortNext)
+	manual.SetNoLeadingFragment(true)
+	manual.SetGapChar('\u2009')
+	manual.SetMaxOutputBytes(1 << 20)
+	manual.SetMergePrefixPadding(true)
+	manual.SetHyphenator(hyphenateAt(2))
+	manual.SetTrailingTrim(";")
+	manual.SetQuoteAware(true)
+	manual.SetHyphenationMinWordLen(3)
+	manual.SetStrictWidth(true)
+	manual.SetPrefixCountsTowardLimit(true)
+
+	got, err := s.Drain()
+	require.NoError(t, err)
+	expected, err := manual.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+}
+
+func TestConfigTabWidth(t *testing.T) {
+	cases := []struct {
+		message  string
+		tabWidth int
+		expected string
+	}{
+		{"Zero should select the default tab width.", 0, "a   b"},
+		{"A positive width should be used as given.", 2, "a b"},
+		{"A negative width should remove tabs.", -1, "ab"},
+	}
+
+	for _, c := range cases {
+		w, err := NewWrapper(Config{Limit: 10, TabWidth: c.tabWidth})
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, w.Wrap("a\tb"), c.message)
+	}
+}
+
+func TestConfigTabStopsCopied(t *testing.T) {
+	stops := []int{4}
+	w, err := NewWrapper(Config{Limit: 10, TabStops: stops})
+	require.NoError(t, err)
+	s, err := ScannerFromConfig(strings.NewReader("a\tb"), Config{Limit: 10, TabStops: stops})
+	require.NoError(t, err)
+
+	stops[0] = 2
+	w.Config().TabStops[0] = 2
+
+	assert.Equal(t, "a   b", w.Wrap("a\tb"))
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "a   b", text)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	cases := []struct {
+		message string
+		cfg     Config
+	}{
+		{"Limit must be positive.", Config{Limit: 0}},
+		{"Control character mode must be known.", Config{Limit: 4, ControlCharMode: 7}},
+		{"Gap character must be one column wide.", Config{Limit: 4, GapChar: '日'}},
+		{"Max output bytes must not be negative.", Config{Limit: 4, MaxOutputBytes: -1}},
+		{"Hyphenation minimum word length must not be negative.", Config{Limit: 4, HyphenationMinWordLen: -1}},
+		{"Break preference must be known.", Config{Limit: 4, BreakPreference: 9}},
+		{"Prefix placement must be known.", Config{Limit: 4, PrefixPlacement: 5}},
+		{"Prefix must be shorter than the limit.", Config{Limit: 4, Prefix: "äöüß"}},
+		{"Wide prefix must be shorter than the limit.", Config{Limit: 4, Prefix: "日本"}},
+		{"Prefix must be shorter than the limit with tabs expanded.", Config{Limit: 4, Prefix: "\t"}},
+		{"Prefix must not contain a newline.", Config{Limit: 4, Prefix: "\n"}},
+		{"Tab stops must be increasing.", Config{Limit: 4, TabStops: []int{4, 2}}},
+		{"Ambiguous width must be 1 or 2.", Config{Limit: 4, AmbiguousWidth: 3}},
+		{"Counted separator must be shorter than the limit.", Config{
+			Limit: 4, GutterSeparator: " || ", GutterSeparatorCountsTowardLimit: true,
+		}},
+		{"Wide counted separator must be shorter than the limit.", Config{
+			Limit: 2, GutterSeparator: "｜", GutterSeparatorCountsTowardLimit: true,
+		}},
+		{"Right margin must be narrower than the limit.", Config{Limit: 4, RightMargin: 4}},
+		{"Right margin must not be negative.", Config{Limit: 4, RightMargin: -1}},
+		{"Fill column must not be negative.", Config{Limit: 4, FillColumn: -1}},
+		{"Overflow limit must not be negative.", Config{Limit: 4, OverflowLimit: -1}},
+		{"Last line mode must be known.", Config{Limit: 4, LastLine: LastLineOfParagraph + 1}},
+		{"Streaming excludes break preferences which buffer.", Config{Limit: 4, Streaming: true, BreakPreference: MinRagged}},
+		{"Streaming excludes a minimum number of words.", Config{Limit: 4, Streaming: true, MinWordsPerLine: 2}},
+		{"Streaming excludes joining words by tabs.", Config{Limit: 4, Streaming: true, TabJoinsWords: true}},
+		{"Streaming excludes sentence breaks.", Config{Limit: 4, Streaming: true, PreferSentenceBreaks: true}},
+	}
+
+	for _, c := range cases {
+		s, err := ScannerFromConfig(strings.NewReader("foo"), c.cfg)
+		assert.Error(t, err, c.message)
+		assert.Nil(t, s, c.message)
+	}
+
+	_, err := NewWrapper(Config{Limit: 4, Streaming: true, BreakPreference: MinRagged})
+	assert.Equal(t, ErrStreaming, err)
+}
@@ -0,0 +1,52 @@
+package wordwrap
+
+// directives maps the name of each directive to the alignment it selects.
+var directives = map[string]Alignment{
+	".left":   AlignLeft,
+	".center": AlignCenter,
+	".right":  AlignRight,
+}
+
+// directive applies the current line of input as a directive if it is one,
+// reporting whether it was. A directive is only recognized at the start of a
+// paragraph, and only if the line holds nothing but the directive's name.
+func (s *Scanner) directive() bool {
+	if !s.cfg.Directives || s.cfg.LineMode || s.inPara || s.line.Count() > 0 || len(s.para) > 0 || s.space.Count() > 0 {
+		return false
+	}
+	align, ok := directives[s.word.String()]
+	if !ok {
+		return false
+	}
+
+	s.align = align
+	s.resetWord()
+	return true
+}
+
+// alignRoom returns the number of columns of space left after a line of the
+// given width within the limit, which includes the right margin if alignment
+// extends into it.
+func (s *Scanner) alignRoom(width int) int {
+	room := s.textLimit() - width
+	if s.cfg.AlignIntoRightMargin {
+		room += s.cfg.RightMargin
+	}
+	return room
+}
+
+// alignPad returns the number of columns of space to place before a line with
+// the given room left after it to align it.
+func alignPad(align Alignment, room int) int {
+	if room <= 0 {
+		return 0
+	}
+
+	switch align {
+	case AlignCenter:
+		return room / 2
+	case AlignRight:
+		return room
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package wordwrap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WrapError describes a failure to read input. It's returned by ReadLine and
+// the methods built on it in place of the error from the underlying reader, or
+// from the handler set with SetErrorHandler, which it wraps for errors.Is and
+// errors.As.
+type WrapError struct {
+	Err error
+
+	// Offset is the number of bytes of input read before the failure, as
+	// counted by ReadLineMapped, and Rune is the number of runes.
+	Offset, Rune int
+
+	// Partial is the text of the line of input which was read but not yet
+	// returned when the read failed.
+	Partial string
+}
+
+func (e *WrapError) Error() string {
+	return fmt.Sprintf("wordwrap: read failed at offset %d: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the error from the reader.
+func (e *WrapError) Unwrap() error {
+	return e.Err
+}
+
+// LineWidthError describes a line wider than the limit, returned in place of
+// the line when SetStrictWidth is enabled. It wraps ErrLineTooWide for
+// errors.Is.
+type LineWidthError struct {
+	// Line is the number of the line, counting from 1 as SetPrefixFunc does.
+	Line int
+
+	// Text is the text of the line, without its prefix or indentation.
+	Text string
+
+	// Width is the display width of the line without its prefix, and Limit is
+	// the width it exceeds.
+	Width, Limit int
+}
+
+func (e *LineWidthError) Error() string {
+	return fmt.Sprintf("wordwrap: line %d is %d columns wide, exceeding the limit of %d", e.Line, e.Width, e.Limit)
+}
+
+// Unwrap returns ErrLineTooWide.
+func (e *LineWidthError) Unwrap() error {
+	return ErrLineTooWide
+}
+
+// Err returns the first error encountered reading input, as a *WrapError, or
+// nil if none has been or the input ended cleanly at EOF. As with
+// bufio.Scanner, it's meant to be checked once reading stops, such as after
+// WriteTo, to tell a failure from the end of input.
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// readError wraps err, from reading input, with the Scanner's position.
+func (s *Scanner) readError(err error, partial string) error {
+	return &WrapError{Err: err, Offset: s.offset, Rune: s.runes, Partial: partial}
+}
+
+// pendingText returns the text of the current line of input which has been
+// read but not laid out in a line, with its whitespace as read.
+func (s *Scanner) pendingText() string {
+	var b strings.Builder
+	b.WriteString(s.line.String())
+	for _, it := range s.para {
+		b.WriteString(it.gap)
+		b.WriteString(it.text)
+	}
+	b.WriteString(s.space.String())
+	b.WriteString(s.word.String())
+	return b.String()
+}
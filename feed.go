@@ -0,0 +1,158 @@
+package wordwrap
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrNeedMore is returned by a Scanner created with NewFeedScanner when no
+// further line can be determined until more input is fed, or the feed closed.
+var ErrNeedMore = errors.New("wordwrap: more input needed")
+
+var errNotFeed = errors.New("wordwrap: scanner was not created by NewFeedScanner")
+
+// NewFeedScanner creates a Scanner which reads input given to it by Feed,
+// rather than pulling it from a reader. This suits event loops which receive
+// input over time: after each call to Feed, lines are read until ReadLine
+// returns ErrNeedMore, which means that no line can be returned until more
+// input is fed. A line is only returned once it's known where it ends, so the
+// last line held may wait on the next word, or on CloseFeed, which marks the
+// end of input.
+//
+// ReadLogicalLine returns ErrNeedMore until the rest of the line has been fed.
+// Otherwise, ErrNeedMore leaves the Scanner as it was, so reading can resume.
+func NewFeedScanner(limit int) *Scanner {
+	return NewScanner(&feedReader{prev: -1}, limit)
+}
+
+// Feed appends p to the input of a Scanner created with NewFeedScanner. It
+// returns an error if the Scanner wasn't created that way or its feed has been
+// closed. The bytes are copied, so p may be reused.
+func (s *Scanner) Feed(p []byte) error {
+	f, ok := s.r.(*feedReader)
+	switch {
+	case !ok:
+		return errNotFeed
+	case f.closed:
+		return errors.New("wordwrap: feed is closed")
+	}
+	f.feed(p)
+	return nil
+}
+
+// Write is Feed for use as an io.Writer. It always consumes all of p unless it
+// returns an error.
+func (s *Scanner) Write(p []byte) (int, error) {
+	if err := s.Feed(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// CloseFeed marks the end of the input of a Scanner created with
+// NewFeedScanner, so that the rest of its lines can be read. It returns an
+// error if the Scanner wasn't created that way.
+func (s *Scanner) CloseFeed() error {
+	f, ok := s.r.(*feedReader)
+	if !ok {
+		return errNotFeed
+	}
+	f.closed = true
+	return nil
+}
+
+// feedReader reads input fed to a Scanner, returning ErrNeedMore when it runs
+// out before it's closed.
+type feedReader struct {
+	buf    []byte
+	off    int // Offset of the next byte to read.
+	prev   int // Offset of the rune last read by ReadRune, or -1.
+	closed bool
+}
+
+func (f *feedReader) feed(p []byte) {
+	// Drop what's been read, keeping the last rune in case it's unread.
+	start := f.off
+	if f.prev >= 0 {
+		start = f.prev
+		f.prev = 0
+	}
+	f.buf = append(f.buf[:0], f.buf[start:]...)
+	f.buf = append(f.buf, p...)
+	f.off -= start
+}
+
+// more returns the error for reading all that's been fed.
+func (f *feedReader) more() error {
+	if f.closed {
+		return io.EOF
+	}
+	return ErrNeedMore
+}
+
+func (f *feedReader) ReadRune() (rune, int, error) {
+	rest := f.buf[f.off:]
+	if len(rest) == 0 || (!f.closed && !utf8.FullRune(rest)) {
+		// The rune last read may still be unread.
+		return 0, 0, f.more()
+	}
+
+	char, size := utf8.DecodeRune(rest)
+	f.prev = f.off
+	f.off += size
+	return char, size, nil
+}
+
+func (f *feedReader) UnreadRune() error {
+	if f.prev < 0 {
+		return errors.New("wordwrap: UnreadRune: previous operation was not ReadRune")
+	}
+	f.off, f.prev = f.prev, -1
+	return nil
+}
+
+func (f *feedReader) Read(p []byte) (int, error) {
+	if f.off == len(f.buf) {
+		return 0, f.more()
+	}
+	n := copy(p, f.buf[f.off:])
+	f.off += n
+	f.prev = -1
+	return n, nil
+}
+
+// hasLine reports whether the input fed but not yet read holds the end of a
+// line, so ReadLogicalLine can read it without running out.
+func (f *feedReader) hasLine(cfg *Config) bool {
+	if f.closed {
+		return true
+	}
+	rest := f.buf[f.off:]
+	for i, w := 0, 0; i < len(rest); i += w {
+		var r rune
+		r, w = utf8.DecodeRune(rest[i:])
+		switch {
+		case r == '\n', r == lineSeparator, r == paragraphSeparator:
+			return true
+		case !cfg.NormalizeNewlines:
+		case r == '\r' && i+w < len(rest), r == '\u0085', r == '\f' && cfg.NormalizeFormFeed:
+			return true
+		}
+	}
+	return false
+}
+
+// hasLogicalEnd reports whether the end of the current line of input has been
+// read, so ReadLogicalLine needs no further input.
+func (s *Scanner) hasLogicalEnd() bool {
+	if s.err != nil {
+		return true
+	}
+	for _, line := range s.lines {
+		if line.brk == breakHard || line.brk == breakEOF {
+			return true
+		}
+	}
+	return false
+}
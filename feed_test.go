@@ -0,0 +1,120 @@
+package wordwrap
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readFed reads lines from s until it needs more input or reaches EOF.
+func readFed(t *testing.T, s *Scanner) []string {
+	var lines []string
+	for {
+		line, err := s.ReadLine()
+		if err == ErrNeedMore || err == io.EOF {
+			return lines
+		}
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+}
+
+func TestFeedScanner(t *testing.T) {
+	s := NewFeedScanner(11)
+	require.NoError(t, s.Feed([]byte("hello wor")))
+	_, err := s.ReadLine()
+	assert.Equal(t, ErrNeedMore, err, "A line should wait for its last word.")
+
+	require.NoError(t, s.Feed([]byte("ld foo")))
+	assert.Empty(t, readFed(t, s), "A line should wait until it's known not to continue.")
+
+	require.NoError(t, s.Feed([]byte(" ")))
+	assert.Equal(t, []string{"hello world"}, readFed(t, s))
+
+	require.NoError(t, s.CloseFeed())
+	assert.Equal(t, []string{"foo"}, readFed(t, s))
+	_, err = s.ReadLine()
+	assert.Equal(t, io.EOF, err)
+	assert.Error(t, s.Feed([]byte("more")), "A closed feed should not accept input.")
+}
+
+func TestFeedScannerSplitInput(t *testing.T) {
+	s := NewFeedScanner(10)
+	s.SetNormalizeNewlines(true, false)
+	require.NoError(t, s.Feed([]byte("a\r")))
+	assert.Empty(t, readFed(t, s), "A carriage return should wait for what follows it.")
+	require.NoError(t, s.Feed([]byte("\nb")))
+	assert.Equal(t, []string{"a"}, readFed(t, s))
+
+	wide := []byte("\n日本")
+	require.NoError(t, s.Feed(wide[:3]))
+	assert.Equal(t, []string{"b"}, readFed(t, s), "A partly fed rune should not be read.")
+	require.NoError(t, s.Feed(wide[3:]))
+	require.NoError(t, s.CloseFeed())
+	assert.Equal(t, []string{"日本"}, readFed(t, s))
+}
+
+func TestFeedScannerMatchesReader(t *testing.T) {
+	// Feeding a byte at a time should give the same lines as reading.
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				s := NewFeedScanner(c.width)
+				s.SetPrefix(c.prefix)
+				if c.setup != nil {
+					c.setup(s)
+				}
+
+				var lines []string
+				for i := 0; i < len(c.text); i++ {
+					require.NoError(t, s.Feed([]byte{c.text[i]}))
+					lines = append(lines, readFed(t, s)...)
+				}
+				require.NoError(t, s.CloseFeed())
+				lines = append(lines, readFed(t, s)...)
+				assert.Equal(t, strings.Split(c.expected, "\n"), lines, c.message)
+			}
+		})
+	}
+}
+
+func TestFeedScannerLogicalLine(t *testing.T) {
+	s := NewFeedScanner(3)
+	fmt.Fprint(s, "abc de ")
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "abc", line)
+
+	_, err = s.ReadLogicalLine()
+	assert.Equal(t, ErrNeedMore, err, "The rest of the line should be awaited.")
+	fmt.Fprint(s, "f\ngh")
+	line, err = s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "de f", line)
+
+	require.NoError(t, s.CloseFeed())
+	assert.Equal(t, []string{"gh"}, readFed(t, s))
+}
+
+func TestFeedScannerMaxLines(t *testing.T) {
+	s := NewFeedScanner(4)
+	s.SetMaxLines(1)
+	s.SetTruncationSuffix(func(n int) string { return fmt.Sprint("+", n) })
+	require.NoError(t, s.Feed([]byte("aaaa bb cc ")))
+	assert.Empty(t, readFed(t, s), "The last line should wait until dropped lines are counted.")
+	require.NoError(t, s.Feed([]byte("dd")))
+	require.NoError(t, s.CloseFeed())
+	assert.Equal(t, []string{"aa+3"}, readFed(t, s))
+}
+
+func TestFeedNotFeedScanner(t *testing.T) {
+	s := NewScanner(strings.NewReader("foo"), 4)
+	assert.Error(t, s.Feed([]byte("bar")))
+	_, err := s.Write([]byte("bar"))
+	assert.Error(t, err)
+	assert.Error(t, s.CloseFeed())
+}
@@ -0,0 +1,70 @@
+package wordwrap
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// SetFootnoteReader sets a reader of footnotes to be placed in the text. Each
+// time marker is read from the main input, it's replaced by the next block of
+// text from r, which is wrapped along with the text around it. Blocks are
+// separated by blank lines, and the lines of a block are joined by spaces. Once
+// r is exhausted, markers are left as they are.
+//
+// Footnotes don't nest: a marker within a footnote is text. Nor are footnotes
+// part of the source, so ReadLineMapped maps their text to the marker's end,
+// and ReadLogicalLine returns markers unexpanded. Errors reading r are returned
+// as errors reading the main input are. A nil r disables footnotes.
+//
+// The footnote reader is input rather than an option, so it isn't part of
+// Config. As with NewScanner, the Scanner takes ownership of r.
+//
+// It's safe to call SetFootnoteReader between calls to ReadLine. Text already
+// read from the previous reader is kept.
+func (s *Scanner) SetFootnoteReader(r io.Reader, marker rune) {
+	s.notes, s.noteMarker = nil, marker
+	if r != nil {
+		rs, ok := r.(runeReader)
+		if !ok {
+			rs = bufio.NewReader(r)
+		}
+		s.notes = rs
+	}
+}
+
+// openFootnote reads the next footnote block to be read in place of a marker.
+// It reports whether there was one.
+func (s *Scanner) openFootnote() (bool, error) {
+	var b strings.Builder
+	newlines := 0
+	for {
+		char, _, err := s.notes.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return false, err
+		}
+
+		if char == '\r' {
+			continue
+		}
+		if char == '\n' {
+			newlines++
+			if newlines == 2 && b.Len() > 0 {
+				// A blank line ends the block.
+				break
+			}
+			continue
+		}
+		if newlines > 0 && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		newlines = 0
+		b.WriteRune(char)
+	}
+
+	block := strings.TrimRight(b.String(), " \t\r")
+	s.note.Reset(block)
+	return block != "", nil
+}
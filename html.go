@@ -0,0 +1,19 @@
+package wordwrap
+
+import "strings"
+
+// defaultHTMLLineBreak separates lines written as HTML unless another is set
+// with SetHTMLLineBreak.
+const defaultHTMLLineBreak = "<br>\n"
+
+// htmlEscaper escapes the characters which would otherwise be read as markup
+// in HTML text.
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// htmlLineBreak returns the separator between lines written as HTML.
+func (c *Config) htmlLineBreak() string {
+	if c.HTMLLineBreak == "" {
+		return defaultHTMLLineBreak
+	}
+	return c.HTMLLineBreak
+}
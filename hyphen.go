@@ -0,0 +1,44 @@
+package wordwrap
+
+import "unicode/utf8"
+
+// breakable reports whether a word too wide for a line may be broken, rather
+// than overflowing the line whole.
+func (s *Scanner) breakable(it item) bool {
+	return !s.keepWhole(it) && utf8.RuneCountInString(it.text) >= s.cfg.HyphenationMinWordLen
+}
+
+// hyphenate breaks text, the rest of a word following skip runes of it, at the
+// last of the word's hyphenation points leaving a head which fits in limit
+// columns along with a hyphen. The head is returned with the hyphen added. It
+// reports false if no point fits.
+func (s *Scanner) hyphenate(text string, points []int, skip, limit int) (string, string, int, bool) {
+	if len(points) == 0 {
+		return "", "", 0, false
+	}
+	hyphen := s.cfg.runeWidth('-')
+	best, bestEnd, bestWidth := 0, 0, 0
+	limit -= hyphen
+	for _, p := range points {
+		p -= skip
+		if p <= best {
+			continue
+		}
+		end, runes := 0, 0
+		for end < len(text) && runes < p {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+			runes++
+		}
+		if end == len(text) {
+			continue
+		}
+		if width := s.cfg.stringWidth(text[:end]); width <= limit {
+			best, bestEnd, bestWidth = p, end, width
+		}
+	}
+	if best == 0 {
+		return "", "", 0, false
+	}
+	return text[:bestEnd] + "-", text[bestEnd:], bestWidth + hyphen, true
+}
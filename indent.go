@@ -0,0 +1,115 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WrapIndented reflows an indented block of text while preserving its
+// indentation. The leading whitespace common to all non-blank lines is removed,
+// the remaining text is reflowed to fit within limit columns less the width of
+// the indentation, and the indentation is then applied to each non-blank line.
+// Paragraphs are separated by blank lines, as with SetReflow, and blank lines
+// are left empty.
+//
+// Indentation is compared by width, with tabs expanded to the default tab
+// width, so lines indented with a mix of tabs and spaces share an indent up to
+// the column they all reach. Each line keeps its text at the column where it
+// began: whitespace beyond the shared indent which holds a tab, or a tab
+// reaching past it, is expanded to spaces.
+func WrapIndented(text string, limit int) string {
+	indent, width := commonIndent(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = trimIndent(line, width)
+	}
+
+	limit -= width
+	if limit < 1 {
+		limit = 1
+	}
+
+	s := NewScanner(strings.NewReader(strings.Join(lines, "\n")), limit)
+	s.SetReflow(true)
+
+	// Reading from a string can't fail. The indent is applied here rather than
+	// as a prefix, which would have its tabs expanded.
+	wrapped, _ := s.Drain()
+	lines = strings.Split(wrapped, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commonIndent returns the leading whitespace shared by every line of text which
+// isn't blank, along with its width. The whitespace is taken from the first such
+// line if it has whitespace ending at that width, and is otherwise spaces.
+func commonIndent(text string) (string, int) {
+	var s Scanner // Measures with the default tab width.
+	var first string
+	width, found := 0, false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		lead := leadingSpace(line)
+		if w := s.gapWidth(lead, 0); !found || w < width {
+			width = w
+		}
+		if !found {
+			first, found = lead, true
+		}
+	}
+
+	col := 0
+	for i, r := range first {
+		if col == width {
+			return first[:i], width
+		}
+		col += s.gapWidth(string(r), col)
+		if col > width {
+			break
+		}
+	}
+	if col == width {
+		return first, width
+	}
+	return strings.Repeat(" ", width), width
+}
+
+// trimIndent removes leading whitespace of the given width from line. If the
+// whitespace left holds a tab, or a tab reaches past the width, the whitespace
+// left is replaced with spaces so the text keeps its column.
+func trimIndent(line string, width int) string {
+	var s Scanner // Measures with the default tab width.
+	col, i := 0, 0
+	for _, r := range leadingSpace(line) {
+		if col >= width {
+			break
+		}
+		col += s.gapWidth(string(r), col)
+		i += utf8.RuneLen(r)
+	}
+
+	rest := line[i:]
+	if col < width {
+		// Only a blank line is indented less.
+		return rest
+	}
+	lead := leadingSpace(rest)
+	if col == width && strings.IndexByte(lead, '\t') < 0 {
+		return rest
+	}
+	end := col + s.gapWidth(lead, col)
+	return strings.Repeat(" ", end-width) + rest[len(lead):]
+}
+
+// leadingSpace returns the whitespace at the start of line.
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+}
@@ -0,0 +1,107 @@
+package wordwrap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapIndented(t *testing.T) {
+	cases := []struct {
+		message  string
+		text     string
+		width    int
+		expected string
+	}{
+		{
+			"An indented paragraph should be reflowed within its indent.",
+			"    The quick brown\n    fox jumps over the\n    lazy dog.", 20,
+			"    The quick brown\n    fox jumps over\n    the lazy dog.",
+		},
+		{
+			"Paragraphs should stay separated by empty lines.",
+			"    one two\n    three\n\n    four five\n    six", 14,
+			"    one two\n    three\n\n    four five\n    six",
+		},
+		{
+			"Deeper indentation should be kept on the first line.",
+			"      one\n    two three", 20,
+			"      one two three",
+		},
+		{
+			"Deeper indentation of continued lines should be dropped.",
+			"    one\n      two three four five", 16,
+			"    one two\n    three four\n    five",
+		},
+		{
+			"Tab indentation should count toward the limit.",
+			"\tone two three four", 12,
+			"\tone two\n\tthree\n\tfour",
+		},
+		{
+			"Continuation lines should align with a mixed indent.",
+			"\t  foo bar baz", 14,
+			"\t  foo bar\n\t  baz",
+		},
+		{
+			"Lines indented to the same column with tabs and spaces should share the indent.",
+			"\t  foo bar\n      baz qux", 14,
+			"\t  foo bar\n\t  baz qux",
+		},
+		{
+			"A tab beyond the shared indent should keep its width.",
+			"  \tfoo bar baz\n  qux", 12,
+			"    foo bar\n  baz qux",
+		},
+		{
+			"Unindented text should be reflowed to the limit.",
+			"one two\nthree four", 9,
+			"one two\nthree\nfour",
+		},
+		{
+			"A trailing newline should be kept.",
+			"  one two three\n", 10,
+			"  one two\n  three\n",
+		},
+		{
+			"Indentation as wide as the limit should leave one column.",
+			"    ab", 4,
+			"    a\n    b",
+		},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, WrapIndented(c.text, c.width), c.message)
+	}
+}
+
+func TestCommonIndent(t *testing.T) {
+	cases := []struct {
+		message string
+		text    string
+		indent  string
+		width   int
+	}{
+		{"The shortest indent should be shared.", "    a\n  b\n      c", "  ", 2},
+		{"Blank lines should be ignored.", "  a\n\n   \n  b", "  ", 2},
+		{"Mixed whitespace should be compared by width.", "\t  a\n      b", "\t  ", 6},
+		{"A tab reaching past the indent should leave spaces.", " \ta\n  b", "  ", 2},
+		{"Unindented text should have no indent.", "a\n  b", "", 0},
+		{"Empty text should have no indent.", "", "", 0},
+	}
+
+	for _, c := range cases {
+		indent, width := commonIndent(c.text)
+		assert.Equal(t, c.indent, indent, c.message)
+		assert.Equal(t, c.width, width, c.message)
+	}
+}
+
+func TestTrimIndent(t *testing.T) {
+	assert.Equal(t, "a", trimIndent("\t  a", 6))
+	assert.Equal(t, "a", trimIndent("      a", 6), "Spaces should match a tab of the same width.")
+	assert.Equal(t, "  a", trimIndent(" \ta", 2), "A tab reaching past the indent should leave spaces.")
+	assert.Equal(t, "  a", trimIndent("  \ta", 2), "A tab following the indent should keep its width.")
+	assert.Equal(t, " a", trimIndent("   a", 2), "Spaces following the indent should be kept.")
+	assert.Equal(t, "", trimIndent("", 2))
+}
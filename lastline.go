@@ -0,0 +1,101 @@
+package wordwrap
+
+import "io"
+
+// LastLineMode selects the lines to which SetLastLinePrefix and
+// SetLastLineSuffix apply.
+type LastLineMode int
+
+const (
+	// LastLineNone marks no lines. This is the default.
+	LastLineNone LastLineMode = iota
+
+	// LastLineOfText marks the last line of text returned. An empty line
+	// standing for a trailing newline follows it unmarked.
+	LastLineOfText
+
+	// LastLineOfParagraph marks the last line of text before each blank line,
+	// as well as the last line of text returned.
+	LastLineOfParagraph
+)
+
+// SetLastLineMode sets which lines are marked as last, to be given the prefix
+// set with SetLastLinePrefix and the suffix set with SetLastLineSuffix, such as
+// to close a box drawn around the text. Whether a line is last isn't known
+// until the line following it has been laid out, or EOF reached, so each line
+// is held until then. Lines cut off by SetMaxLines aren't returned, so the last
+// line returned is always marked. Defaults to LastLineNone.
+//
+// It's safe to call SetLastLineMode between calls to ReadLine.
+func (s *Scanner) SetLastLineMode(mode LastLineMode) {
+	s.cfg.LastLine = mode
+}
+
+// SetLastLinePrefix sets the prefix applied to lines marked as last in place
+// of the prefix set with SetPrefix or SetPrefixFunc. It may be empty, or
+// shorter than the usual prefix, to dedent the line. If no lines are marked as
+// last, it selects LastLineOfText.
+//
+// It's safe to call SetLastLinePrefix between calls to ReadLine.
+func (s *Scanner) SetLastLinePrefix(prefix string) {
+	s.cfg.LastLinePrefix = prefix
+	s.markLastLines()
+}
+
+// SetLastLineSuffix sets a suffix appended to lines marked as last. The suffix
+// doesn't count toward the limit. If no lines are marked as last, it selects
+// LastLineOfText.
+//
+// It's safe to call SetLastLineSuffix between calls to ReadLine.
+func (s *Scanner) SetLastLineSuffix(suffix string) {
+	s.cfg.LastLineSuffix = suffix
+	s.markLastLines()
+}
+
+// markLastLines selects LastLineOfText if no lines are marked as last.
+func (s *Scanner) markLastLines() {
+	if s.cfg.LastLine == LastLineNone {
+		s.cfg.LastLine = LastLineOfText
+	}
+}
+
+// markLast marks a line being returned as last if it's selected by the last
+// line mode. This reads ahead past any blank lines until the next line of text
+// is laid out. A read error while reading ahead is left to be returned in
+// place of the lines following.
+func (s *Scanner) markLast(line *pendingLine) error {
+	if s.cfg.LastLine == LastLineNone || line.text == "" {
+		return nil
+	}
+
+	for i := 0; ; i++ {
+		err := s.fill(i)
+		switch {
+		case err == io.EOF:
+			line.last = true
+			return nil
+		case err == ErrNeedMore:
+			return err
+		case err != nil:
+			return nil
+		}
+
+		next := s.lines[i]
+		if next.text != "" {
+			return nil
+		}
+		if next.brk == breakEOF || s.cfg.LastLine == LastLineOfParagraph {
+			line.last = true
+			return nil
+		}
+	}
+}
+
+// linePrefix returns the function computing the prefix of a line being
+// returned.
+func (s *Scanner) linePrefix(line pendingLine) func() string {
+	if line.last && s.cfg.LastLine != LastLineNone {
+		return func() string { return s.cfg.LastLinePrefix }
+	}
+	return s.currentPrefix
+}
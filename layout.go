@@ -0,0 +1,603 @@
+package wordwrap
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// BreakPreference selects the heuristic a Scanner uses to choose line breaks.
+type BreakPreference int
+
+const (
+	// MaxFit places as many words as fit on each line. This is the default.
+	MaxFit BreakPreference = iota
+
+	// MinRagged chooses breaks which minimize variation in line length,
+	// measured as the sum of the squared unused width of all but the last line.
+	MinRagged
+
+	// BreakBeforeShort places as many words as fit on each line, except that a
+	// short word which would end a line is moved to the start of the next line
+	// when it fits there. Short words are those one or two columns wide.
+	BreakBeforeShort
+)
+
+// Alignment positions each line of a paragraph within the limit.
+type Alignment int
+
+const (
+	// AlignLeft places each line at the start of the limit. This is the
+	// default.
+	AlignLeft Alignment = iota
+
+	// AlignCenter centers each line within the limit, placing any odd column
+	// of space after the line.
+	AlignCenter
+
+	// AlignRight places each line at the end of the limit.
+	AlignRight
+)
+
+// maxShortWord is the widest word considered short by BreakBeforeShort.
+const maxShortWord = 2
+
+// gluePenalty is added to the cost of breaking a line within a run of words
+// joined by tabs, so MinRagged does so only when the run can't fit on a line.
+const gluePenalty = math.MaxInt32
+
+// lineBreak describes how a line ended.
+type lineBreak int
+
+const (
+	breakSoft lineBreak = iota // Wrapped between words.
+	breakWord                  // Wrapped within a word too long to fit.
+	breakHard                  // Ended by a newline.
+	breakEOF                   // Ended by the end of input.
+)
+
+// pendingLine is a line which has been laid out but not yet returned.
+type pendingLine struct {
+	text       string
+	brk        lineBreak
+	pad        int // Columns of space aligning the line within the limit.
+	align      Alignment
+	room       int    // Columns of space left after the line, from which pad is taken.
+	indent     int    // Columns of space following the padding, before the text.
+	start, end int    // Source range of the text.
+	suffix     string // Marks the line as truncated, following the text.
+	trimmed    int    // Runes of whitespace trimmed from the end of the line.
+	last       bool   // Ends the output, or its paragraph, as SetLastLineMode selects.
+}
+
+// wrapped reports whether the line was broken to fit the limit.
+func (l pendingLine) wrapped() bool {
+	return l.brk == breakSoft || l.brk == breakWord
+}
+
+// item is a word along with the whitespace preceding it.
+type item struct {
+	gap    string // Whitespace with tabs not yet expanded.
+	text   string
+	width  int
+	forced bool // Must begin a line, as with pieces of a word too long to fit.
+	split  bool // Continues the previous item within the same word.
+	glued  bool // Separated from the previous item by tabs which join them.
+	quoted bool // Holds a quoted span, kept whole where it fits.
+
+	// Source offsets of the gap, the text, and the end of the text. If the
+	// text differs from its source, ends holds the offset following each rune.
+	gapStart, start, end int
+	ends                 []int
+}
+
+// breakLine ends the line being laid out. A line continuing the same line of
+// input begins at the continuation indent.
+func (s *Scanner) breakLine(brk lineBreak) {
+	start, end := s.lineSource()
+	text, width := s.lineText(start, end), s.lineWidth
+	if s.cfg.TrailingTrim != "" {
+		trimmed := s.cfg.trimTrailing(text)
+		width -= s.cfg.stringWidth(text[len(trimmed):])
+		text = trimmed
+	}
+	line := pendingLine{
+		text:    text,
+		brk:     brk,
+		indent:  s.lineIndent,
+		start:   start,
+		end:     end,
+		trimmed: s.lineTrim,
+	}
+	if s.line.Count() > 0 {
+		line.align, line.room = s.align, s.alignRoom(width)
+		line.pad = alignPad(line.align, line.room)
+	}
+	s.lines = append(s.lines, line)
+	if s.layoutLimit != 0 && s.layoutLimit != s.cfg.Limit && s.cfg.OnLimitChange != nil {
+		s.cfg.OnLimitChange(s.layoutLimit, s.cfg.Limit)
+	}
+	s.layoutLimit = s.cfg.Limit
+	if brk == breakWord && s.cfg.CollectStats {
+		s.stats.ForcedBreaks++
+	}
+	s.line.Reset()
+	s.lineTrim = 0
+	s.lineMapped = false
+	if brk == breakSoft || brk == breakWord {
+		// Tabs on continuation lines don't set the indent.
+		s.aligned = true
+		s.lineIndent = s.indent
+	} else {
+		s.indent, s.lineIndent, s.aligned = 0, 0, false
+	}
+	s.lineWidth = s.lineIndent
+}
+
+// writeLine appends text of the given width to the line being laid out.
+func (s *Scanner) writeLine(text string, width int) {
+	s.line.WriteString(text)
+	s.lineWidth += width
+}
+
+// place appends an item to the line being laid out, first breaking the line if
+// the item doesn't fit. Whitespace preceding the item is dropped on a break.
+func (s *Scanner) place(it item) {
+	col := s.lineWidth
+	gap, gapWidth := s.expandGap(it.gap, col)
+	fits := col+gapWidth+it.width <= s.textLimit()
+	if !fits && s.cfg.BreakHook != nil && s.line.Count() > 0 {
+		fits = s.vetoesBreak(s.line.String(), it.text, col)
+	}
+	if !it.forced && fits {
+		s.writeGap(it, gap, gapWidth)
+		s.writeItem(it)
+		return
+	}
+
+	if s.line.Count() > 0 {
+		s.lineTrim = utf8.RuneCountInString(it.gap)
+		s.breakLine(softBreak(it))
+	}
+	s.writeItem(it)
+}
+
+// placeAnywhere appends an item to the line being laid out as place does, but
+// breaks the item wherever the line fills, as with SetBreakAnywhere.
+func (s *Scanner) placeAnywhere(it item) {
+	if s.cfg.Verbatim && it.gap != "" {
+		s.placeSpace(it.gap, it.gapStart)
+		it.gap, it.gapStart = "", it.start
+	}
+	for {
+		gap, gapWidth := s.expandGap(it.gap, s.lineWidth)
+		room := s.textLimit() - s.lineWidth - gapWidth
+		if it.width <= room {
+			s.writeGap(it, gap, gapWidth)
+			s.writeItem(it)
+			return
+		}
+
+		head, tail, width := s.splitText(it.text, room)
+		if width > room && (s.line.Count() > 0 || it.gap != "") {
+			// Nothing fits after the whitespace, so the item begins a line.
+			if s.line.Count() > 0 {
+				s.lineTrim = utf8.RuneCountInString(it.gap)
+				s.breakLine(softBreak(it))
+			}
+			it.gap, it.gapStart = "", it.start
+			continue
+		}
+
+		end := it.sourceEnd(len(head))
+		s.writeGap(it, gap, gapWidth)
+		s.writeItem(item{text: head, width: width, start: it.start, end: end})
+		if tail == "" {
+			return
+		}
+		s.breakLine(breakWord)
+
+		rest := item{text: tail, width: it.width - width, split: true, gapStart: end, start: end, end: it.end}
+		if it.ends != nil {
+			rest.ends = it.ends[utf8.RuneCountInString(head):]
+		}
+		it = rest
+	}
+}
+
+// writeGap appends the whitespace preceding an item, rendered as gap, to the
+// line being laid out.
+func (s *Scanner) writeGap(it item, gap string, width int) {
+	if gap != "" {
+		s.mapSource(it.gapStart, it.start)
+	}
+	s.writeLine(gap, width)
+}
+
+// writeItem appends the text of an item to the line being laid out.
+func (s *Scanner) writeItem(it item) {
+	s.mapSource(it.start, it.end)
+	s.writeLine(it.text, it.width)
+}
+
+// placeTrailing appends whitespace ending a line of input to the line being
+// laid out, truncated to fit within the limit.
+func (s *Scanner) placeTrailing(gap string) {
+	gap, _ = s.expandGap(gap, s.lineWidth)
+	room, width := s.textLimit()-s.lineWidth, 0
+	for i, r := range gap {
+		if width+s.cfg.runeWidth(r) > room {
+			s.lineTrim = utf8.RuneCountInString(gap[i:])
+			gap = gap[:i]
+			break
+		}
+		width += s.cfg.runeWidth(r)
+	}
+	s.writeLine(gap, width)
+}
+
+// placeSpace appends whitespace beginning at the given source offset to the line
+// being laid out, breaking the line wherever the whitespace reaches the limit
+// rather than dropping any of it, as with SetVerbatim.
+func (s *Scanner) placeSpace(gap string, start int) {
+	for i, r := range gap {
+		raw := gap[i : i+utf8.RuneLen(r)]
+		text, width := s.expandGap(raw, s.lineWidth)
+		if s.line.Count() > 0 && s.lineWidth+width > s.textLimit() {
+			s.breakLine(breakSoft)
+			text, width = s.expandGap(raw, s.lineWidth)
+		}
+		s.mapSource(start+i, start+i+len(raw))
+		s.writeLine(text, width)
+	}
+}
+
+// softBreak returns the kind of break made before an item beginning a line.
+func softBreak(it item) lineBreak {
+	if it.split {
+		return breakWord
+	}
+	return breakSoft
+}
+
+// layoutParagraph lays out a complete line of input according to the break
+// preference.
+func (s *Scanner) layoutParagraph(items []item) {
+	limit, tab := s.textLimit(), len(items)
+	if s.bullet {
+		s.indent, tab = s.bulletIndent(items, limit)
+	} else if s.cfg.AlignContinuationToTab {
+		s.indent, tab = s.tabIndent(items, limit)
+	}
+
+	// Words before the first tab fit on the first line, so only those after it
+	// are split to fit after the indent.
+	split := s.splitLong(nil, items[:tab], limit)
+	split = s.splitLong(split, items[tab:], limit-s.indent)
+	starts := s.lineStarts(split, limit)
+	if s.indent > 0 && len(starts) > 1 && starts[1] <= tab {
+		// The first tab didn't land on the first line, so nothing is aligned.
+		s.indent = 0
+		split = s.splitLong(split[:tab], items[tab:], limit)
+		starts = s.lineStarts(split, limit)
+	}
+	items = split
+
+	for n, start := range starts {
+		end := len(items)
+		if n+1 < len(starts) {
+			end = starts[n+1]
+		}
+		if n > 0 {
+			s.lineTrim = utf8.RuneCountInString(items[start].gap)
+			s.breakLine(softBreak(items[start]))
+		}
+
+		for i, it := range items[start:end] {
+			if i > 0 || s.leadWidth(it, start == 0, limit) > it.width {
+				gap, width := s.expandGap(it.gap, s.lineWidth)
+				s.writeGap(it, gap, width)
+			}
+			s.writeItem(it)
+		}
+	}
+}
+
+// lineStarts returns the index of the first item on each line according to the
+// break preference.
+func (s *Scanner) lineStarts(items []item, limit int) []int {
+	var starts []int
+	if s.linePref == MinRagged {
+		starts = s.minRaggedBreaks(items, limit)
+	} else {
+		starts = s.greedyBreaks(items, limit, s.linePref == BreakBeforeShort, s.lineSentence)
+	}
+	if s.lineMin > 1 {
+		s.fillShortLines(items, starts, limit, s.lineMin)
+	}
+	return starts
+}
+
+// greedyBreaks returns the index of the first item on each line when placing as
+// many items as fit on each line. If avoidShort is set, short words are moved
+// from the end of a line to the start of the next where possible. If
+// atSentences is set, lines break after the last sentence which ends on them
+// instead.
+func (s *Scanner) greedyBreaks(items []item, limit int, avoidShort, atSentences bool) []int {
+	starts := []int{0}
+	for i := 0; i < len(items); {
+		j, width := i+1, s.leadWidth(items[i], i == 0, limit)
+		if i > 0 {
+			width += s.indent
+		}
+		for j < len(items) && !items[j].forced {
+			next := width + s.gapWidth(items[j].gap, width) + items[j].width
+			if next > limit && (s.cfg.BreakHook == nil || !s.vetoesBreak(s.runText(items[i:j], i == 0, limit), items[j].text, width)) {
+				break
+			}
+			width = next
+			j++
+		}
+
+		if j < len(items) && items[j].glued {
+			// Break before the glued run instead, unless it began the line.
+			k := j
+			for k > i+1 && items[k].glued {
+				k--
+			}
+			if !items[k].glued {
+				j = k
+			}
+		}
+
+		moved := false
+		if atSentences && j < len(items) {
+			for k := j; k > i; k-- {
+				if endsSentence(items[k-1].text) && !items[k].glued && !items[k].forced {
+					moved = k < j
+					j = k
+					break
+				}
+			}
+		}
+
+		if avoidShort && !moved && j < len(items) && j-i > 1 && !items[j].forced && !items[j].glued && !items[j-1].glued {
+			short := items[j-1].width
+			start := s.indent + short
+			if short <= maxShortWord && start+s.gapWidth(items[j].gap, start)+items[j].width <= limit {
+				j--
+			}
+		}
+
+		if j < len(items) {
+			starts = append(starts, j)
+		}
+		i = j
+	}
+	return starts
+}
+
+// endsSentence reports whether a word ends with sentence-ending punctuation,
+// which may be followed by closing quotes or brackets.
+func endsSentence(word string) bool {
+	word = strings.TrimRight(word, "\"')]}\u2019\u201d")
+	return strings.HasSuffix(word, ".") || strings.HasSuffix(word, "!") || strings.HasSuffix(word, "?")
+}
+
+// minRaggedBreaks returns the index of the first item on each line for breaks
+// minimizing the sum of squared unused width on all but the last line.
+func (s *Scanner) minRaggedBreaks(items []item, limit int) []int {
+	if s.canBalance(items) {
+		return s.balancedBreaks(items, limit)
+	}
+	return s.dynamicBreaks(items, limit)
+}
+
+// dynamicBreaks returns the breaks for minRaggedBreaks by trying every line
+// which fits, measuring each item's gap at the column where it begins.
+func (s *Scanner) dynamicBreaks(items []item, limit int) []int {
+	n := len(items)
+	cost := make([]int, n+1)
+	prev := make([]int, n+1)
+	for j := 1; j <= n; j++ {
+		cost[j] = math.MaxInt
+	}
+
+	for i := 0; i < n; i++ {
+		width := s.leadWidth(items[i], i == 0, limit)
+		if i > 0 {
+			width += s.indent
+		}
+		for j := i + 1; j <= n; j++ {
+			if j > i+1 {
+				if items[j-1].forced {
+					break
+				}
+				width += s.gapWidth(items[j-1].gap, width) + items[j-1].width
+				if width > limit {
+					break
+				}
+			}
+
+			c := cost[i]
+			if j < n {
+				c += (limit - width) * (limit - width)
+				if items[j].glued {
+					// Breaking within a glued run is a last resort.
+					c += gluePenalty
+				}
+			}
+			if c < cost[j] {
+				cost[j], prev[j] = c, i
+			}
+		}
+	}
+
+	return breakStarts(prev)
+}
+
+// fillShortLines adjusts the index of the first item on each line so that lines
+// other than the last hold at least n items where possible, by moving items
+// down from the end of the previous line.
+func (s *Scanner) fillShortLines(items []item, starts []int, limit, n int) {
+	for k := 1; k+1 < len(starts); k++ {
+		for starts[k+1]-starts[k] < n && starts[k]-starts[k-1] > n {
+			if items[starts[k]].forced || items[starts[k]-1].glued {
+				break
+			}
+			if s.runWidth(items[starts[k]-1:starts[k+1]]) > limit {
+				break
+			}
+			starts[k]--
+		}
+	}
+}
+
+// runWidth returns the width of items laid out on a line other than the first
+// of a paragraph, including the continuation indent.
+func (s *Scanner) runWidth(items []item) int {
+	width := s.indent + items[0].width
+	for _, it := range items[1:] {
+		width += s.gapWidth(it.gap, width) + it.width
+	}
+	return width
+}
+
+// leadWidth returns the width of an item beginning a line. Whitespace preceding
+// the first item of a paragraph is kept if it fits; it's dropped elsewhere.
+func (s *Scanner) leadWidth(it item, first bool, limit int) int {
+	if first && !it.forced {
+		if width := s.gapWidth(it.gap, 0) + it.width; width <= limit {
+			return width
+		}
+	}
+	return it.width
+}
+
+// splitLong appends items to dst, breaking words wider than limit into pieces
+// which each begin a line. Words kept whole overflow the limit instead.
+func (s *Scanner) splitLong(dst, items []item, limit int) []item {
+	for _, it := range items {
+		if it.width <= limit || !s.breakable(it) {
+			dst = append(dst, it)
+			continue
+		}
+
+		var points []int
+		if s.cfg.Hyphenator != nil {
+			points = s.cfg.Hyphenator(it.text)
+		}
+		start, skip := it.start, 0
+		for text, split := it.text, false; text != ""; split = true {
+			var head, tail string
+			var width int
+			ok := false
+			if len(points) > 0 && split {
+				// The rest of the word is hyphenated again only if it overflows.
+				head, width = text, s.cfg.stringWidth(text)
+				ok = width <= limit
+			}
+			if !ok {
+				head, tail, width, ok = s.hyphenate(text, points, skip, limit)
+			}
+			if !ok {
+				head, tail, width = s.splitText(text, limit)
+			}
+			skip += utf8.RuneCountInString(text) - utf8.RuneCountInString(tail)
+			end := it.sourceEnd(len(it.text) - len(tail))
+			dst = append(dst, item{
+				text:     head,
+				width:    width,
+				forced:   true,
+				split:    split,
+				gapStart: start,
+				start:    start,
+				end:      end,
+			})
+			text, start = tail, end
+		}
+	}
+	return dst
+}
+
+// expandGap renders whitespace beginning at the given column, replacing tabs
+// with spaces, or the tab fill, aligned on the tab width. It returns the result
+// and its width.
+func (s *Scanner) expandGap(gap string, col int) (string, int) {
+	if strings.IndexByte(gap, '\t') < 0 {
+		return gap, s.cfg.stringWidth(gap)
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range gap {
+		if r == '\t' {
+			n := s.cfg.tabAdvance(col + width)
+			if s.cfg.DebugWhitespace && n > 0 {
+				// Render marks where the tab begins.
+				b.WriteByte('\t')
+				b.WriteString(strings.Repeat(" ", n-1))
+			} else {
+				b.WriteString(s.cfg.tabFill(n))
+			}
+			width += n
+		} else {
+			b.WriteRune(r)
+			width += s.cfg.runeWidth(r)
+		}
+	}
+	return b.String(), width
+}
+
+// gapWidth returns the width of whitespace beginning at the given column.
+func (s *Scanner) gapWidth(gap string, col int) int {
+	width := 0
+	for _, r := range gap {
+		if r == '\t' {
+			width += s.cfg.tabAdvance(col + width)
+		} else {
+			width += s.cfg.runeWidth(r)
+		}
+	}
+	return width
+}
+
+// alignTab sets the continuation indent for the current line of input if the
+// item's gap holds the line's first tab and the line hasn't yet wrapped. The
+// indent is the column following the tab, provided the item fits after it.
+func (s *Scanner) alignTab(it item) {
+	if !s.cfg.AlignContinuationToTab || s.aligned {
+		return
+	}
+	i := strings.IndexByte(it.gap, '\t')
+	if i < 0 {
+		return
+	}
+
+	s.aligned = true
+	col := s.lineWidth + s.gapWidth(it.gap[:i], s.lineWidth)
+	col += s.cfg.tabAdvance(col)
+	if col+it.width <= s.textLimit() {
+		s.indent = col
+	}
+}
+
+// tabIndent returns the continuation indent for a line of input laid out as a
+// whole, along with the index of the item preceded by its first tab. The indent
+// is the column following the tab were the line not wrapped, or zero if there's
+// no tab or the item doesn't fit after it.
+func (s *Scanner) tabIndent(items []item, limit int) (int, int) {
+	col := 0
+	for n, it := range items {
+		if i := strings.IndexByte(it.gap, '\t'); i >= 0 {
+			col += s.gapWidth(it.gap[:i], col)
+			col += s.cfg.tabAdvance(col)
+			if col+it.width > limit {
+				return 0, n
+			}
+			return col, n
+		}
+		col += s.gapWidth(it.gap, col) + it.width
+	}
+	return 0, len(items)
+}
@@ -0,0 +1,130 @@
+package wordwrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakPreference(t *testing.T) {
+	cases := []struct {
+		message  string
+		pref     BreakPreference
+		text     string
+		width    int
+		expected string
+	}{
+		{
+			"MaxFit should fill each line.",
+			MaxFit, "aaa bb cc ddddd", 6,
+			"aaa bb\ncc\nddddd",
+		},
+		{
+			"MinRagged should even out line lengths.",
+			MinRagged, "aaa bb cc ddddd", 6,
+			"aaa\nbb cc\nddddd",
+		},
+		{
+			"MinRagged should ignore the length of the last line.",
+			MinRagged, "aaa bb cc d", 6,
+			"aaa bb\ncc d",
+		},
+		{
+			"MinRagged should keep newlines and leading space.",
+			MinRagged, "  aaa bb cc ddddd\nfoo", 7,
+			"  aaa\nbb cc\nddddd\nfoo",
+		},
+		{
+			"MinRagged should break long words.",
+			MinRagged, "a stupendous b", 4,
+			"a\nstup\nendo\nus b",
+		},
+		{
+			"MaxFit should leave short words at the end of a line.",
+			MaxFit, "one two a three", 9,
+			"one two a\nthree",
+		},
+		{
+			"BreakBeforeShort should move short words to the next line.",
+			BreakBeforeShort, "one two a three", 9,
+			"one two\na three",
+		},
+		{
+			"BreakBeforeShort should keep short words which don't fit the next line.",
+			BreakBeforeShort, "one two a threeeee", 9,
+			"one two a\nthreeeee",
+		},
+		{
+			"BreakBeforeShort should match MaxFit without short words.",
+			BreakBeforeShort, "These words should split across lines", 20,
+			"These words should\nsplit across lines",
+		},
+	}
+
+	for _, c := range cases {
+		s := NewScanner(strings.NewReader(c.text), c.width)
+		s.SetBreakPreference(c.pref)
+		text, err := s.Drain()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, text, c.message)
+	}
+}
+
+func TestChangeBreakPreference(t *testing.T) {
+	s := NewScanner(strings.NewReader("aaa bb cc ddddd\naaa bb cc ddddd"), 6)
+
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "aaa bb", line)
+
+	// The new preference applies from the next line of input.
+	s.SetBreakPreference(MinRagged)
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "cc\nddddd\naaa\nbb cc\nddddd", text)
+}
+
+func TestMinWordsPerLine(t *testing.T) {
+	cases := []struct {
+		message  string
+		min      int
+		text     string
+		expected string
+	}{
+		{
+			"Without a minimum, lines should be filled greedily.",
+			0, "one two three four fivesixseven eight",
+			"one two three\nfour\nfivesixseven\neight",
+		},
+		{
+			"Words should move down to a short line.",
+			2, "one two three four fivesixseven eight",
+			"one two\nthree four\nfivesixseven\neight",
+		},
+		{
+			"Words should not move when the previous line would become short.",
+			3, "one two three four fivesixseven eight",
+			"one two three\nfour\nfivesixseven\neight",
+		},
+		{
+			"Words should not move when they don't fit.",
+			2, "aaa bbb ccc ddddddddddddddd eee",
+			"aaa bbb ccc\nddddddddddddddd\neee",
+		},
+		{
+			"Pieces of long words should be left alone.",
+			2, "one two three abcdefghijklmnopqrstuvwxyz",
+			"one two three\nabcdefghijklmnop\nqrstuvwxyz",
+		},
+	}
+
+	for _, c := range cases {
+		s := NewScanner(strings.NewReader(c.text), 16)
+		s.SetMinWordsPerLine(c.min)
+		text, err := s.Drain()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, text, c.message)
+	}
+}
@@ -0,0 +1,98 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// breakClass is a line breaking class of Unicode Standard Annex #14, reduced to
+// the classes which govern breaks within East Asian text.
+type breakClass int
+
+const (
+	classOther breakClass = iota
+	classID               // Ideographs and kana, between which lines may break.
+	classOP               // Opening punctuation, after which lines never break.
+	classCL               // Closing punctuation, before which lines never break.
+	classEX               // Exclamation and question marks.
+	classNS               // Nonstarters, such as iteration marks.
+	classCJ               // Small kana and the prolonged sound mark.
+)
+
+// breakClasses lists the East Asian punctuation and kana whose class isn't
+// simply ID.
+var breakClasses = map[rune]breakClass{
+	'（': classOP, '「': classOP, '『': classOP, '【': classOP, '〔': classOP,
+	'〈': classOP, '《': classOP, '〖': classOP, '〘': classOP, '［': classOP,
+	'｛': classOP, '“': classOP, '‘': classOP,
+
+	'）': classCL, '」': classCL, '』': classCL, '】': classCL, '〕': classCL,
+	'〉': classCL, '》': classCL, '〗': classCL, '〙': classCL, '］': classCL,
+	'｝': classCL, '”': classCL, '’': classCL, '、': classCL, '。': classCL,
+	'，': classCL, '．': classCL,
+
+	'！': classEX, '？': classEX,
+
+	'・': classNS, '々': classNS, '〻': classNS, 'ゝ': classNS, 'ゞ': classNS,
+	'ヽ': classNS, 'ヾ': classNS, '：': classNS, '；': classNS,
+
+	'ぁ': classCJ, 'ぃ': classCJ, 'ぅ': classCJ, 'ぇ': classCJ, 'ぉ': classCJ,
+	'っ': classCJ, 'ゃ': classCJ, 'ゅ': classCJ, 'ょ': classCJ, 'ゎ': classCJ,
+	'ゕ': classCJ, 'ゖ': classCJ, 'ァ': classCJ, 'ィ': classCJ, 'ゥ': classCJ,
+	'ェ': classCJ, 'ォ': classCJ, 'ッ': classCJ, 'ャ': classCJ, 'ュ': classCJ,
+	'ョ': classCJ, 'ヮ': classCJ, 'ヵ': classCJ, 'ヶ': classCJ, 'ー': classCJ,
+}
+
+// classOf returns the line breaking class of r.
+func classOf(r rune) breakClass {
+	if class, ok := breakClasses[r]; ok {
+		return class
+	}
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) {
+		return classID
+	}
+	return classOther
+}
+
+// breaksBefore reports whether the word being read may break before char,
+// without whitespace between them.
+func (s *Scanner) breaksBefore(char rune) bool {
+	if s.cfg.BreakOnUnderscore && s.lastRune == '_' && char != '_' &&
+		strings.Trim(s.word.String(), "_") != "" {
+		return true
+	}
+	return s.cfg.UnicodeLineBreaks && s.breakBetween(s.lastRune, char)
+}
+
+// breakBetween reports whether a line may break between two adjacent runes of a
+// word. Lines may break before or after East Asian characters, except before
+// closing punctuation and nonstarters, and after opening punctuation. Small
+// kana are nonstarters under strict rules, selected by a Japanese locale, and
+// ideographs otherwise.
+func (s *Scanner) breakBetween(a, b rune) bool {
+	before, after := classOf(a), classOf(b)
+	if before == classOther && after == classOther {
+		return false
+	}
+
+	switch {
+	case before == classOP:
+		return false
+	case after == classCL, after == classEX, after == classNS:
+		return false
+	case after == classCJ:
+		return !s.strictBreaks()
+	}
+	return true
+}
+
+// strictBreaks reports whether the locale calls for strict line breaking. Only
+// Japanese is tailored, as kinsoku shori forbids lines beginning with small
+// kana.
+func (s *Scanner) strictBreaks() bool {
+	lang := s.cfg.Locale
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.EqualFold(lang, "ja")
+}
@@ -0,0 +1,78 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	arabicDecimal   = '\u066B' // Arabic decimal separator.
+	arabicThousands = '\u066C' // Arabic thousands separator.
+)
+
+// isNumber reports whether word is a number, kept whole by SetKeepNumbers. A
+// number is a run of digits, optionally divided into groups by single periods,
+// commas or colons, as in "1,234,567", "192.168.0.1" or "12:30". It may have a
+// leading sign, and may be followed by punctuation ending a clause or sentence.
+// Digits of any script are recognized, and Arabic decimal and thousands
+// separators divide groups as periods and commas do.
+func isNumber(word string) bool {
+	word = strings.TrimRight(word, ".,;:!?)]\"'")
+	word = strings.TrimLeft(word, "+-")
+	if word == "" {
+		return false
+	}
+
+	digit := false // The previous rune was a digit.
+	for _, r := range word {
+		switch {
+		case unicode.IsDigit(r):
+			digit = true
+		case digit && (r == '.' || r == ',' || r == ':' || r == arabicDecimal || r == arabicThousands):
+			digit = false
+		default:
+			return false
+		}
+	}
+	return digit
+}
+
+// splitText splits text as splitWidth does, but not within a run of digits if
+// KeepDigitGroups is set. The text is split before the run instead, or after it
+// if the run begins the text, even if it's wider than limit.
+func (s *Scanner) splitText(text string, limit int) (string, string, int) {
+	head, tail, width := s.cfg.splitWidth(text, limit)
+	if !s.cfg.KeepDigitGroups || tail == "" {
+		return head, tail, width
+	}
+	last, _ := utf8.DecodeLastRuneInString(head)
+	next, _ := utf8.DecodeRuneInString(tail)
+	if !unicode.IsDigit(last) || !unicode.IsDigit(next) {
+		return head, tail, width
+	}
+
+	notDigit := func(r rune) bool { return !unicode.IsDigit(r) }
+	if i := strings.LastIndexFunc(head, notDigit); i >= 0 {
+		_, n := utf8.DecodeRuneInString(head[i:])
+		head, tail = head[:i+n], head[i+n:]+tail
+	} else {
+		i := strings.IndexFunc(tail, notDigit)
+		if i < 0 {
+			i = len(tail)
+		}
+		head, tail = head+tail[:i], tail[i:]
+	}
+	return head, tail, s.cfg.stringWidth(head)
+}
+
+// keepWhole reports whether an item is never to be broken within its text.
+func (s *Scanner) keepWhole(it item) bool {
+	if it.split {
+		return false
+	}
+	if (s.cfg.NoLeadingFragment || it.quoted) && !s.cfg.Verbatim && it.width <= s.textLimit() {
+		return true
+	}
+	return s.cfg.KeepNumbers && isNumber(it.text)
+}
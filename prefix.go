@@ -0,0 +1,85 @@
+package wordwrap
+
+import (
+	"errors"
+	"strings"
+)
+
+// PrefixPlacement positions the prefix on lines aligned to the center or right.
+type PrefixPlacement int
+
+const (
+	// PrefixMargin places the prefix at the start of each line, with any padding
+	// between it and the text. This is the default.
+	PrefixMargin PrefixPlacement = iota
+
+	// PrefixHug places the prefix after any padding, immediately before the
+	// text.
+	PrefixHug
+)
+
+// validPrefix reports whether prefix can be applied to each line.
+func (c *Config) validPrefix(prefix string) error {
+	switch {
+	case strings.IndexFunc(prefix, isLineBreak) >= 0:
+		return ErrPrefixNewline
+	case c.stringWidth(c.expandPrefix(prefix)) >= c.Limit:
+		return errors.New("wordwrap: prefix must be shorter than limit")
+	}
+	return nil
+}
+
+// expandPrefix prepares a prefix for output. Tabs are expanded to spaces on the
+// tab stops, counted from the start of the line, so the prefix has a fixed
+// width. Line breaks, which would split the line, are replaced with spaces.
+func (c *Config) expandPrefix(prefix string) string {
+	if strings.IndexFunc(prefix, func(r rune) bool { return r == '\t' || isLineBreak(r) }) < 0 {
+		return prefix
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range prefix {
+		switch {
+		case r == '\t':
+			n := c.tabAdvance(width)
+			b.WriteString(c.tabFill(n))
+			width += n
+		case isLineBreak(r):
+			b.WriteByte(' ')
+			width++
+		default:
+			b.WriteRune(r)
+			width += c.runeWidth(r)
+		}
+	}
+	return b.String()
+}
+
+// lead returns the text preceding a line's text: the prefix, expanded with
+// expandPrefix, and the gutter separator, padded to a tab stop if
+// AlignPrefixToTab is set.
+func (c *Config) lead(prefix string) string {
+	lead := c.expandPrefix(prefix) + c.GutterSeparator
+	if !c.AlignPrefixToTab {
+		return lead
+	}
+	width := c.stringWidth(lead)
+	if width == 0 {
+		return lead
+	}
+	// The next stop at or after width follows the column before it.
+	if n := c.stopAdvance(width-1) - 1; n > 0 {
+		lead += strings.Repeat(" ", n)
+	}
+	return lead
+}
+
+// isLineBreak reports whether r ends a line of text.
+func isLineBreak(r rune) bool {
+	switch r {
+	case '\n', '\v', '\f', '\r', '\u0085', lineSeparator, paragraphSeparator:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package wordwrap
+
+import "unicode"
+
+// trackQuote follows quoted spans as each rune of a word is read, before it's
+// written to the word. A quote opens a span unless it follows a letter or digit
+// of the word, and the matching quote closes it. Within a span, a backslash
+// escapes the rune following it.
+func (s *Scanner) trackQuote(char rune) {
+	switch {
+	case s.quote == 0:
+		attached := s.word.Count() > 0 && (unicode.IsLetter(s.lastRune) || unicode.IsDigit(s.lastRune))
+		if (char == '"' || char == '\'') && !attached {
+			s.quote = char
+			s.wordQuoted = true
+		}
+	case s.quoteEscaped:
+		s.quoteEscaped = false
+	case char == '\\':
+		s.quoteEscaped = true
+	case char == s.quote:
+		s.quote = 0
+	}
+}
@@ -0,0 +1,89 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// holdNewline begins holding a newline while reflowing. Whether it joins two
+// lines or ends a paragraph depends on the line which follows.
+func (s *Scanner) holdNewline() {
+	if s.word.Count() == 0 {
+		s.space.Reset()
+		s.glue = false
+	}
+	s.joinPending = true
+}
+
+// resolveNewline decides the fate of a held newline given the rune following
+// it. It reports whether the rune was consumed.
+func (s *Scanner) resolveNewline(char rune) bool {
+	switch {
+	case char == '\n':
+		// A blank line ends the paragraph.
+		s.joinPending = false
+		s.endLine(breakHard)
+		s.endLine(breakHard)
+		return true
+	case char == lineSeparator || char == paragraphSeparator:
+		// Explicit separators break lines as usual.
+		s.joinPending = false
+		return false
+	case isBreakingSpace(char):
+		// Indentation of a continued line is dropped.
+		return true
+	}
+
+	s.joinPending = false
+	if word := s.word.String(); s.cfg.Dehyphenate && hyphenated(word) {
+		if unicode.IsLower(char) && !compoundHead(word) {
+			s.word.Reset()
+			s.word.WriteString(strings.TrimSuffix(word, "-"))
+			s.wordEnds = s.wordEnds[:len(s.wordEnds)-1]
+		}
+		// The word continues past the newline.
+		s.wordEdited = true
+		return false
+	}
+	s.endWord()
+	s.space.WriteRune(s.cfg.gapChar())
+	s.joined = true
+	return false
+}
+
+// hasContent reports whether any text of the current line of input has been
+// read.
+func (s *Scanner) hasContent() bool {
+	return s.line.Count() > 0 || len(s.para) > 0 || s.word.Count() > 0
+}
+
+// hyphenated reports whether word ends with a hyphen following a letter, as a
+// word broken across lines would.
+func hyphenated(word string) bool {
+	if !strings.HasSuffix(word, "-") {
+		return false
+	}
+	r, _ := utf8.DecodeLastRuneInString(strings.TrimSuffix(word, "-"))
+	return unicode.IsLetter(r)
+}
+
+// compoundHeads holds common first parts of hyphenated compounds, which are
+// rarely the first syllable of a word broken across lines.
+var compoundHeads = map[string]struct{}{
+	"all": {}, "cross": {}, "full": {}, "half": {}, "much": {}, "self": {}, "well": {},
+}
+
+// compoundHead reports whether word, ending in a hyphen, looks like the start
+// of a hyphenated compound rather than a word broken across lines: it either
+// holds an earlier hyphen, as in "state-of-the-", or what precedes the hyphen
+// is in compoundHeads, as in "well-".
+func compoundHead(word string) bool {
+	head := strings.TrimSuffix(word, "-")
+	if strings.ContainsRune(head, '-') {
+		return true
+	}
+	head = strings.TrimLeftFunc(head, func(r rune) bool { return !unicode.IsLetter(r) })
+	_, ok := compoundHeads[strings.ToLower(head)]
+	return ok
+}
@@ -5,6 +5,15 @@ import (
 	"unicode/utf8"
 )
 
+// CountRunes returns the number of runes in s, counted as the Scanner counts
+// them when buffering text. Each combining mark counts as a rune of its own,
+// and each byte of invalid UTF-8 counts as one rune. It's a count of runes, not
+// of columns, so it doesn't match how lines are wrapped for wide or combining
+// text; StringWidth measures that.
+func CountRunes(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
 type runeBuffer struct {
 	buf       bytes.Buffer
 	runeCount int
@@ -23,7 +32,7 @@ func (b *runeBuffer) WriteRune(r rune) (n int, err error) {
 
 func (b *runeBuffer) WriteString(s string) (n int, err error) {
 	n, err = b.buf.WriteString(s)
-	b.runeCount += utf8.RuneCount([]byte(s[:n]))
+	b.runeCount += CountRunes(s[:n])
 	return
 }
 
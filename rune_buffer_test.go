@@ -41,6 +41,27 @@ func TestWriteString(t *testing.T) {
 	fmt.Println(b.String())
 }
 
+func TestCountRunes(t *testing.T) {
+	for _, s := range []string{"", "Test", "Käse", "日本語", "😀", "e\u0301\u0327", "a\xffb"} {
+		b := runeBuffer{}
+		b.WriteString(s)
+		assert.Equal(t, b.Count(), CountRunes(s), "The count of %q should match the buffer.", s)
+	}
+	assert.Equal(t, 3, CountRunes("e\u0301\u0327"), "Combining marks should be counted.")
+	assert.Equal(t, 3, CountRunes("a\xffb"), "Invalid bytes should be counted as runes.")
+}
+
+func TestStringWidth(t *testing.T) {
+	for _, s := range []string{"Test", "Käse", "日本語", "😀", "e\u0301\u0327 x", "👨\u200d👩\u200d👧"} {
+		lines := WrapRunes([]rune(s), StringWidth(s))
+		assert.Len(t, lines, 1, "%q should fit a line of its width.", s)
+	}
+	assert.Equal(t, 6, StringWidth("日本語"), "Wide characters should take two columns.")
+	assert.Equal(t, 3, CountRunes("日本語"))
+	assert.Equal(t, 1, StringWidth("e\u0301\u0327"), "Combining marks should take no columns.")
+	assert.Equal(t, 0, StringWidth(""))
+}
+
 func TestBufWriteTo(t *testing.T) {
 	const s = "Test"
 	b1 := runeBuffer{}
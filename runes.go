@@ -0,0 +1,79 @@
+package wordwrap
+
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// WrapRunes wraps runes to the given limit using the default configuration,
+// returning each line separately as Lines does. The result is the same as
+// wrapping string(runes), which the caller needn't build. Invalid runes are
+// read as utf8.RuneError, as they would be once encoded.
+func WrapRunes(runes []rune, limit int) []string {
+	// Reading from a slice can't fail.
+	lines, _ := NewScanner(&runeSliceReader{runes: runes, prev: -1}, limit).ReadAll()
+	return lines
+}
+
+// runeSliceReader reads from a slice of runes, encoding them as UTF-8 only when
+// read as bytes.
+type runeSliceReader struct {
+	runes []rune
+	i     int    // Index of the next rune to read.
+	prev  int    // Index of the rune last read by ReadRune, or -1.
+	part  []byte // Remainder of a rune partly read by Read.
+}
+
+func (r *runeSliceReader) ReadRune() (rune, int, error) {
+	r.prev = -1
+	if len(r.part) > 0 {
+		// The rest of a rune split by Read is invalid alone.
+		size := len(r.part)
+		r.part = nil
+		return utf8.RuneError, size, nil
+	}
+	if r.i >= len(r.runes) {
+		return 0, 0, io.EOF
+	}
+
+	char := r.runes[r.i]
+	if !utf8.ValidRune(char) {
+		char = utf8.RuneError
+	}
+	r.prev = r.i
+	r.i++
+	return char, utf8.RuneLen(char), nil
+}
+
+func (r *runeSliceReader) UnreadRune() error {
+	if r.prev < 0 {
+		return errors.New("wordwrap: UnreadRune: previous operation was not ReadRune")
+	}
+	r.i, r.prev = r.prev, -1
+	return nil
+}
+
+func (r *runeSliceReader) Read(p []byte) (int, error) {
+	r.prev = -1
+	n := copy(p, r.part)
+	r.part = r.part[n:]
+	for n < len(p) && r.i < len(r.runes) {
+		char := r.runes[r.i]
+		r.i++
+		if utf8.RuneLen(char) <= len(p)-n {
+			n += utf8.EncodeRune(p[n:], char)
+			continue
+		}
+
+		var buf [utf8.UTFMax]byte
+		size := utf8.EncodeRune(buf[:], char)
+		m := copy(p[n:], buf[:size])
+		r.part = buf[m:size]
+		n += m
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
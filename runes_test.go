@@ -0,0 +1,67 @@
+package wordwrap
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapRunes(t *testing.T) {
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				if c.prefix != "" || c.setup != nil {
+					continue
+				}
+				expected := strings.Split(c.expected, "\n")
+				assert.Equal(t, expected, WrapRunes([]rune(c.text), c.width), c.message)
+			}
+		})
+	}
+
+	assert.Equal(t, []string{"� �"}, WrapRunes([]rune{0xD800, ' ', -1}, 4),
+		"Invalid runes should be read as replacement characters.")
+}
+
+func TestRuneSliceReaderScanner(t *testing.T) {
+	// Every option should behave as it does when reading from a string.
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				s := NewScanner(&runeSliceReader{runes: []rune(c.text), prev: -1}, c.width)
+				s.SetPrefix(c.prefix)
+				if c.setup != nil {
+					c.setup(s)
+				}
+				got, err := s.Drain()
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, got, c.message)
+			}
+		})
+	}
+}
+
+func TestRuneSliceReaderRead(t *testing.T) {
+	r := &runeSliceReader{runes: []rune("aé日b"), prev: -1}
+	char, size, err := r.ReadRune()
+	require.NoError(t, err)
+	assert.Equal(t, 'a', char)
+	assert.Equal(t, 1, size)
+
+	// A rune split across reads should be completed by the next read.
+	p := make([]byte, 3)
+	n, err := r.Read(p)
+	require.NoError(t, err)
+	assert.Equal(t, "é\xe6", string(p[:n]))
+	assert.Error(t, r.UnreadRune(), "Only ReadRune may be undone.")
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "\x97\xa5b", string(rest))
+
+	_, _, err = r.ReadRune()
+	assert.Equal(t, io.EOF, err)
+}
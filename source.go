@@ -0,0 +1,119 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// writeWord appends a rune to the word being read, along with the source offset
+// following it.
+func (s *Scanner) writeWord(r rune, end int) {
+	if s.word.Count() == 0 {
+		s.wordStart = s.runeStart
+	}
+	s.word.WriteRune(r)
+	s.wordEnds = append(s.wordEnds, end)
+	s.lastRune = r
+}
+
+// writeSpace appends a rune to the whitespace preceding the word being read.
+func (s *Scanner) writeSpace(r rune) {
+	if s.space.Count() == 0 {
+		s.spaceStart = s.runeStart
+	}
+	s.space.WriteRune(r)
+}
+
+// resetWord clears the word being read.
+func (s *Scanner) resetWord() {
+	s.word.Reset()
+	s.wordEnds = s.wordEnds[:0]
+	s.wordEdited = false
+	s.wordQuoted = false
+}
+
+// sourceItem returns the word being read as an item, along with the source
+// range of it and its preceding whitespace.
+func (s *Scanner) sourceItem() item {
+	text := s.word.String()
+	replaced := false
+	if s.cfg.Replacer != nil {
+		if r := s.cfg.Replacer.Replace(text); r != text {
+			text, replaced = r, true
+		}
+	}
+	it := item{
+		gap:   s.space.String(),
+		text:  text,
+		width: s.cfg.stringWidth(text),
+		start: s.wordStart,
+		end:   s.wordEnds[len(s.wordEnds)-1],
+	}
+	it.gapStart = it.start
+	if s.space.Count() > 0 {
+		it.gapStart = s.spaceStart
+	}
+	switch {
+	case replaced:
+		// Replaced runes have no source of their own, so each is mapped to the
+		// end of the word.
+		it.ends = make([]int, utf8.RuneCountInString(text))
+		for i := range it.ends {
+			it.ends[i] = it.end
+		}
+	case s.wordEdited:
+		// The text no longer matches the source byte for byte.
+		it.ends = append([]int(nil), s.wordEnds...)
+	}
+	return it
+}
+
+// sourceEnd returns the source offset following the first n bytes of the item's
+// text, which must end a rune.
+func (it item) sourceEnd(n int) int {
+	switch {
+	case n == len(it.text):
+		return it.end
+	case it.ends == nil:
+		return it.start + n
+	}
+	return it.ends[utf8.RuneCountInString(it.text[:n])-1]
+}
+
+// mapSource extends the source range of the line being laid out to cover text
+// from start to end.
+func (s *Scanner) mapSource(start, end int) {
+	if !s.lineMapped {
+		s.lineStart, s.lineMapped = start, true
+	}
+	s.lineEnd = end
+}
+
+// lineText returns the text of the line being laid out, which has the given
+// source range. When the text matches its source byte for byte, as it does
+// unless whitespace within it was collapsed or expanded, it's returned as a
+// substring of the source rather than copied.
+func (s *Scanner) lineText(start, end int) string {
+	if start <= end && end <= len(s.src) && string(s.line.buf.Bytes()) == s.src[start:end] {
+		return s.src[start:end]
+	}
+	return s.line.String()
+}
+
+// newStringScanner creates a Scanner over text, as NewScanner does given a
+// strings.Reader, which returns lines needing no alteration as substrings of
+// text rather than copies.
+func newStringScanner(text string, limit int) *Scanner {
+	s := NewScanner(strings.NewReader(text), limit)
+	s.src = text
+	return s
+}
+
+// lineSource returns the source range of the line being laid out. A line
+// without text has an empty range where it ends.
+func (s *Scanner) lineSource() (int, int) {
+	if !s.lineMapped {
+		return s.runeStart, s.runeStart
+	}
+	return s.lineStart, s.lineEnd
+}
@@ -0,0 +1,19 @@
+package wordwrap
+
+// Stats reports the work done by a Scanner, to help diagnose slow wrapping.
+type Stats struct {
+	Runes int // Runes read from the input.
+	Lines int // Lines returned.
+
+	// The following are only counted while SetCollectStats is enabled.
+	ForcedBreaks int // Lines broken within a word too long to fit.
+	WidthCalls   int // Lookups of the display width of a rune.
+}
+
+// Stats returns the counts of work done by the Scanner so far.
+func (s *Scanner) Stats() Stats {
+	stats := s.stats
+	stats.Runes = s.runes
+	stats.Lines = s.lineNum
+	return stats
+}
@@ -0,0 +1,106 @@
+package wordwrap
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// truncate marks a line as the last to be returned when more lines follow it,
+// shortening it to make room for the truncation suffix.
+func (s *Scanner) truncate(line pendingLine) (pendingLine, error) {
+	dropped, err := s.countDropped()
+	if err != nil || dropped == 0 {
+		return line, err
+	}
+
+	suffix := s.cfg.Ellipsis
+	if s.cfg.TruncationSuffix != nil {
+		suffix = s.cfg.TruncationSuffix(dropped)
+	}
+	// A suffix too wide for the line is cut, so the line never exceeds it.
+	room := s.textLimit() - line.pad - line.indent
+	suffix = s.cutWidth(suffix, room)
+	room -= s.cfg.stringWidth(suffix)
+	line.text = strings.TrimRightFunc(s.cutWidth(line.text, room), unicode.IsSpace)
+	line.suffix = suffix
+	return line, nil
+}
+
+// countDropped reads past the last line to be returned, reporting how many
+// lines are dropped. Without a truncation suffix, only whether any are dropped
+// matters, so it reads no further than the first. The empty line standing for
+// a trailing newline isn't counted. Lines counted before more input must be fed
+// stay counted for the next call.
+func (s *Scanner) countDropped() (int, error) {
+	for {
+		line, err := s.takeLine()
+		if err == io.EOF {
+			return s.dropped, nil
+		} else if err != nil {
+			return 0, err
+		}
+
+		if line.brk != breakEOF || line.text != "" {
+			s.dropped++
+		}
+		if s.cfg.TruncationSuffix == nil && s.dropped > 0 {
+			return s.dropped, nil
+		}
+	}
+}
+
+// outputText returns text as WriteTo writes it.
+func (s *Scanner) outputText(text string) string {
+	if s.cfg.HTMLOutput {
+		return htmlEscaper.Replace(text)
+	}
+	return text
+}
+
+// cutOutput returns as much of a line with the given prefix and text as fits in
+// room bytes when written by WriteTo. The prefix is kept whole or dropped along
+// with the text, and the text is cut between runes.
+func (s *Scanner) cutOutput(lead, text string, room int) string {
+	lead = s.outputText(lead)
+	if len(lead) > room {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(lead)
+	for len(text) > 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		next := s.outputText(text[:size])
+		if b.Len()+len(next) > room {
+			break
+		}
+		b.WriteString(next)
+		text = text[size:]
+	}
+	return b.String()
+}
+
+// capWidth cuts a line to be returned to the width set with SetTruncateOverflow,
+// ending it with the ellipsis if it was cut.
+func (s *Scanner) capWidth(line string) string {
+	limit := s.cfg.OverflowLimit
+	if limit <= 0 || s.cfg.stringWidth(line) <= limit {
+		return line
+	}
+
+	ellipsis := s.cutWidth(s.cfg.OverflowEllipsis, limit)
+	return s.cutWidth(line, limit-s.cfg.stringWidth(ellipsis)) + ellipsis
+}
+
+// cutWidth returns the longest prefix of text no wider than width columns.
+func (s *Scanner) cutWidth(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	head, _, w := s.cfg.splitWidth(text, width)
+	if w > width {
+		return ""
+	}
+	return head
+}
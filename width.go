@@ -0,0 +1,144 @@
+package wordwrap
+
+import (
+	"unicode"
+
+	"golang.org/x/text/width"
+)
+
+// StringWidth returns the number of columns s occupies when displayed, as the
+// Scanner measures it with the default configuration when wrapping lines. Wide
+// characters occupy two columns and combining marks none, and ambiguous
+// characters occupy one.
+func StringWidth(s string) int {
+	return (&Config{}).stringWidth(s)
+}
+
+// runeWidth returns the number of columns a rune occupies when displayed. East
+// Asian wide and fullwidth characters occupy two columns, and ambiguous
+// characters occupy AmbiguousWidth columns. Nonspacing and enclosing combining
+// marks occupy none, as they're drawn over the preceding character, as do zero
+// width joiners and emoji modifiers, which alter the emoji they join. All others
+// occupy one.
+func (c *Config) runeWidth(r rune) int {
+	if c.stats != nil {
+		c.stats.WidthCalls++
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me) || r == zeroWidthJoiner || isEmojiModifier(r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	case width.EastAsianAmbiguous:
+		if c.AmbiguousWidth == 2 {
+			return 2
+		}
+	}
+	return 1
+}
+
+// stringWidth returns the number of columns text occupies when displayed. A
+// grapheme cluster is as wide as the rune beginning it, so an emoji joined to
+// the one before it adds nothing, except that the regional indicators of a flag
+// each add their own width.
+func (c *Config) stringWidth(text string) int {
+	n := 0
+	var cl cluster
+	for _, r := range text {
+		if cl.breaksBefore(r) || isRegionalIndicator(r) {
+			n += c.runeWidth(r)
+		}
+	}
+	return n
+}
+
+// splitWidth splits text after as many grapheme clusters as fit within limit
+// columns, so combining marks stay with the character they follow and emoji
+// sequences stay whole. At least one cluster is always taken, so a cluster
+// wider than the limit overflows it rather than stalling. It returns both parts
+// and the width of the first.
+func (c *Config) splitWidth(text string, limit int) (string, string, int) {
+	n, cut, cutWidth := 0, 0, 0
+	var cl cluster
+	for i, r := range text {
+		brk := cl.breaksBefore(r)
+		if brk && i > 0 {
+			switch {
+			case n <= limit:
+				cut, cutWidth = i, n
+			case cut == 0:
+				// The first cluster overflows the limit, so it's taken alone.
+				return text[:i], text[i:], n
+			default:
+				return text[:cut], text[cut:], cutWidth
+			}
+		}
+		if brk || isRegionalIndicator(r) {
+			n += c.runeWidth(r)
+		}
+	}
+	if n <= limit || cut == 0 {
+		return text, "", n
+	}
+	return text[:cut], text[cut:], cutWidth
+}
+
+// zeroWidthJoiner joins characters into a single grapheme cluster, as in emoji
+// sequences such as families.
+const zeroWidthJoiner = '\u200D'
+
+// cluster tracks the grapheme cluster being scanned, following the rules of
+// Unicode Standard Annex #29 which matter for breaking text: no break before
+// extending characters, such as combining marks, variation selectors, and
+// emoji modifiers, nor within a pair of regional indicators forming a flag.
+// Zero width joiners only join emoji, so joiners elsewhere may be broken
+// around.
+type cluster struct {
+	prev     rune
+	regional int  // regional indicators ending with prev
+	emoji    bool // whether the cluster began with a pictograph
+}
+
+// breaksBefore reports whether a cluster boundary falls before r, then
+// advances past r.
+func (cl *cluster) breaksBefore(r rune) bool {
+	brk := true
+	switch {
+	case unicode.IsMark(r), unicode.Is(unicode.Other_Grapheme_Extend, r), isEmojiModifier(r):
+		brk = false
+	case r == zeroWidthJoiner && cl.emoji:
+		brk = false
+	case cl.prev == zeroWidthJoiner && cl.emoji && isPictographic(r):
+		brk = false
+	case isRegionalIndicator(r) && cl.regional%2 == 1:
+		brk = false
+	}
+	if brk {
+		cl.emoji = isPictographic(r)
+	}
+	cl.prev = r
+	if isRegionalIndicator(r) {
+		cl.regional++
+	} else {
+		cl.regional = 0
+	}
+	return brk
+}
+
+// isPictographic reports whether r is a symbol such as an emoji, which zero
+// width joiners join into sequences.
+func isPictographic(r rune) bool {
+	return unicode.Is(unicode.So, r)
+}
+
+// isRegionalIndicator reports whether r is one of the letters paired to spell
+// flags.
+func isRegionalIndicator(r rune) bool {
+	return r >= '\U0001F1E6' && r <= '\U0001F1FF'
+}
+
+// isEmojiModifier reports whether r is a skin tone modifier.
+func isEmojiModifier(r rune) bool {
+	return r >= '\U0001F3FB' && r <= '\U0001F3FF'
+}
@@ -3,166 +3,1172 @@ package wordwrap
 
 import (
 	"bufio"
+	"bytes"
+	"hash"
 	"io"
 	"strings"
 	"unicode"
 )
 
+// ControlCharMode determines how a Scanner renders control characters other
+// than whitespace: the C0 controls, DEL, and the C1 controls.
+type ControlCharMode int
+
+const (
+	// ControlCharPass emits control characters unchanged, each counting as a
+	// single character. This is the default.
+	ControlCharPass ControlCharMode = iota
+
+	// ControlCharStrip removes control characters from the output.
+	ControlCharStrip
+
+	// ControlCharCaret renders control characters in caret notation, such as
+	// "^A" for U+0001 and "^?" for DEL, as done by "cat -v". C1 controls have
+	// "M-" before the notation of the C0 control they mirror, such as "M-^A"
+	// for U+0081.
+	ControlCharCaret
+)
+
+// Unicode separators which break lines in addition to '\n'.
+const (
+	lineSeparator      = '\u2028'
+	paragraphSeparator = '\u2029'
+)
+
+// maxEmptyReads is the number of reads in a row which may consume no input
+// before reading fails with io.ErrNoProgress, as with bufio.Reader.
+const maxEmptyReads = 100
+
+// runeReader is a reader which can also be read one rune at a time.
+type runeReader interface {
+	io.Reader
+	io.RuneScanner
+}
+
 // Scanner wraps UTF-8 encoded text at word boundaries when lines exceed a limit
-// number of characters. Newlines are preserved, including consecutive and
-// trailing newlines, though trailing whitespace is stripped from each line.
+// number of columns. East Asian wide and fullwidth characters occupy two
+// columns and nonspacing combining marks, such as U+0301, occupy none; all
+// others occupy one unless set by SetAmbiguousWidth. A word broken to fit is
+// never broken before a combining mark. Newlines are preserved, including
+// consecutive and trailing newlines, though trailing whitespace is stripped
+// from each line unless disabled with SetTrimTrailingSpace. The Unicode line
+// separator (U+2028) is treated as a newline, and the paragraph separator
+// (U+2029) as a newline followed by an empty line.
 //
-// Clients should not assume Scanner is thread-safe.
+// Clients should not assume Scanner is thread-safe. To share a configuration
+// between goroutines, use a Wrapper.
 type Scanner struct {
-	r        io.RuneScanner
-	limit    int
-	prefix   string
-	tabWidth int
+	r   runeReader
+	cfg Config
 
 	// Scan state
-	err         error
-	line        runeBuffer
-	word        runeBuffer
-	space       runeBuffer
-	needNewline bool
-	skipNextWS  bool // Skip non-newline whitespace if true.
+	err          error
+	readErr      error           // Error from a look-ahead read, returned by the next read.
+	lineNum      int             // Number of lines returned so far.
+	leadBytes    int             // Bytes of prefix beginning the line last returned.
+	lines        []pendingLine   // Lines laid out but not yet returned.
+	line         runeBuffer      // The line being laid out.
+	lineWidth    int             // Display width of line.
+	word         runeBuffer      // The word being read.
+	space        runeBuffer      // Whitespace preceding the word being read.
+	para         []item          // Words awaiting paragraph layout.
+	linePref     BreakPreference // Break preference for the current line of input.
+	lineMin      int             // Minimum words per line for the current line of input.
+	joinPending  bool            // A newline is held while reflowing.
+	glue         bool            // Space holds only tabs following a word.
+	lineGlue     bool            // Tabs join words on the current line of input.
+	lineSentence bool            // Lines break at sentences on the current line of input.
+	inPara       bool            // The current line of input continues a paragraph.
+	align        Alignment       // Alignment of the current paragraph.
+	indent       int             // Continuation indent for the current line of input.
+	lineIndent   int             // Indent of the line being laid out.
+	lineTrim     int             // Runes of whitespace trimmed from the end of line.
+	aligned      bool            // The continuation indent is settled.
+	blankRun     int             // Blank lines of input since the last paragraph.
+	seenText     bool            // A paragraph has been read.
+	afterNewline bool            // No text follows the newline ending the last line of input.
+	lineWords    int             // Words read from the current line of input.
+	bullet       bool            // The current line of input begins with a bullet marker.
+	joined       bool            // The word being read begins a line joined by reflowing.
+	layoutLimit  int             // Limit at which the last line was laid out, or 0 before the first.
+	quote        rune            // Quote opening the span being read, or 0 outside quotes.
+	quoteEscaped bool            // The next rune of the quoted span is escaped.
+	wordQuoted   bool            // The word being read holds a quoted span.
+
+	stats   Stats // Counts of work done, collected while CollectStats is set.
+	dropped int   // Lines counted as dropped by MaxLines.
+
+	// Footnotes
+	notes      runeReader     // Reader of footnote blocks, or nil.
+	noteMarker rune           // Rune replaced by the next footnote block.
+	note       strings.Reader // Remainder of the footnote being read.
+
+	// Source mapping, in bytes read from r
+	src        string // Text read from r, if known in advance, as when wrapping a string.
+	offset     int    // Bytes read so far.
+	runes      int    // Runes read so far.
+	lastSize   int    // Size of the rune last read.
+	runeStart  int    // Offset of the rune being scanned.
+	wordStart  int    // Offset of word.
+	wordEnds   []int  // Offset following each rune of word.
+	lastRune   rune   // Last rune of word.
+	wordEdited bool   // Word differs from its source, as when control characters are escaped.
+	spaceStart int    // Offset of space.
+	lineStart  int    // Offset of the text of line.
+	lineEnd    int    // Offset following the text of line.
+	lineMapped bool   // Line holds text with a source range.
 }
 
 // NewScanner creates and initializes a new Scanner given a reader and fixed
 // line limit. The new Scanner takes ownership of the reader, and the caller
 // should not use it after this call.
 func NewScanner(r io.Reader, limit int) *Scanner {
-	rs, ok := r.(io.RuneScanner)
+	rs, ok := r.(runeReader)
 	if !ok {
 		rs = bufio.NewReader(r)
 	}
-	return &Scanner{r: rs, limit: limit, tabWidth: 4}
+	return &Scanner{r: rs, cfg: Config{Limit: limit}}
+}
+
+// SetLimit sets the limit given to NewScanner: the number of columns to which
+// lines are wrapped. The new limit applies to text laid out after the call;
+// lines the scanner has already laid out but not yet returned keep the old
+// limit.
+//
+// It's safe to call SetLimit between calls to ReadLine.
+func (s *Scanner) SetLimit(limit int) {
+	s.cfg.Limit = limit
+}
+
+// Limit returns the number of columns to which lines are wrapped, as given to
+// NewScanner or SetLimit.
+func (s *Scanner) Limit() int {
+	return s.cfg.Limit
+}
+
+// SetWidth is an alias for SetLimit.
+//
+// Deprecated: Use SetLimit, which is named for the limit given to NewScanner.
+func (s *Scanner) SetWidth(width int) {
+	s.SetLimit(width)
 }
 
 // SetPrefix sets a string to prefix each future line. The prefix is not applied
 // to empty lines and the prefix's length is not included in the character limit
-// specified in NewScanner.
+// specified in NewScanner, unless SetPrefixCountsTowardLimit is enabled.
+// Leading whitespace kept at the start of a line of input follows the prefix,
+// so "foo\n  bar" with the prefix ">" yields ">foo" and ">  bar".
+//
+// Tabs in the prefix are expanded to spaces on the tab stops set with
+// SetTabWidth or SetTabStops, counting from the start of the line. A prefix
+// can't hold a line break, which would split the line; any line breaks are
+// replaced with spaces. SetPrefixErr rejects such a prefix instead. The same
+// applies to prefixes from SetPrefixFunc and ReadLinePrefixed.
 //
-// It's safe to call SetPrefix between calls to ReadLine.
+// It's safe to call SetPrefix between calls to ReadLine. The prefix is applied
+// as each line is returned, so a new prefix also affects text which was read
+// but not yet returned, such as the continuation of a wrapped line.
 func (s *Scanner) SetPrefix(prefix string) {
-	s.prefix = prefix
+	s.cfg.Prefix = prefix
+}
+
+// SetPrefixErr is like SetPrefix, but returns an error rather than setting a
+// prefix which isn't valid: ErrPrefixNewline if it contains a line break, or an
+// error if it's at least as wide as the limit once tabs are expanded.
+//
+// It's safe to call SetPrefixErr between calls to ReadLine.
+func (s *Scanner) SetPrefixErr(prefix string) error {
+	if err := s.cfg.validPrefix(prefix); err != nil {
+		return err
+	}
+	s.cfg.Prefix = prefix
+	return nil
 }
 
-// SetTabWidth sets the width of tab characters.
+// SetPrefixFunc sets a function which computes the prefix for each future line,
+// overriding any prefix set with SetPrefix. The function receives the 1-based
+// number of the line being returned. As with SetPrefix, the prefix is not
+// applied to empty lines, though they are still counted. Pass nil to restore
+// the static prefix.
+//
+// It's safe to call SetPrefixFunc between calls to ReadLine.
+func (s *Scanner) SetPrefixFunc(f func(line int) string) {
+	s.cfg.PrefixFunc = f
+}
+
+// SetPrefixOnBlankLines sets whether the prefix is applied to empty lines, as
+// when quoting a message in which blank lines must remain quoted. It's never
+// applied to the empty line representing a trailing newline. Defaults to false.
+//
+// It's safe to call SetPrefixOnBlankLines between calls to ReadLine.
+func (s *Scanner) SetPrefixOnBlankLines(enable bool) {
+	s.cfg.PrefixOnBlankLines = enable
+}
+
+// SetTrimPrefixOnBlank sets whether trailing whitespace is trimmed from the
+// prefix when it's applied to an empty line, so a "> " prefix yields ">" rather
+// than a line ending in whitespace. Defaults to false.
+//
+// It's safe to call SetTrimPrefixOnBlank between calls to ReadLine.
+func (s *Scanner) SetTrimPrefixOnBlank(enable bool) {
+	s.cfg.TrimPrefixOnBlank = enable
+}
+
+// SetPrefixCountsTowardLimit sets whether the prefix set with SetPrefix counts
+// toward the limit, so the prefix and text together never exceed it. The
+// prefix's display width, with tabs expanded, is deducted, so a wide character
+// takes two columns from the text. A prefix as wide as the limit or wider
+// leaves a single column for text, which is wrapped as it would be at a limit
+// of 1. A prefix from SetPrefixFunc or ReadLinePrefixed isn't known until its
+// line is returned, so it isn't counted. Defaults to false.
+//
+// It's safe to call SetPrefixCountsTowardLimit between calls to ReadLine.
+func (s *Scanner) SetPrefixCountsTowardLimit(enable bool) {
+	s.cfg.PrefixCountsTowardLimit = enable
+}
+
+// SetGutterSeparator sets a separator placed between the prefix and the text of
+// each non-empty line, such as "│ " following a line number gutter. If
+// countsTowardLimit is true, the separator's display width, as measured for
+// text, is deducted from the limit so the combined separator and text never
+// exceed it.
+//
+// It's safe to call SetGutterSeparator between calls to ReadLine.
+func (s *Scanner) SetGutterSeparator(sep string, countsTowardLimit bool) {
+	s.cfg.GutterSeparator = sep
+	s.cfg.GutterSeparatorCountsTowardLimit = countsTowardLimit
+}
+
+// SetTrimTrailingSpace sets whether whitespace at the end of each line of
+// input, before a newline or EOF, is removed. When disabled, the whitespace is
+// kept as far as it fits within the limit, with tabs expanded. Whitespace at
+// which a line wraps is always removed. Defaults to true.
+//
+// It's safe to call SetTrimTrailingSpace between calls to ReadLine.
+func (s *Scanner) SetTrimTrailingSpace(trim bool) {
+	s.cfg.KeepTrailingSpace = !trim
+}
+
+// SetTrailingTrim sets runes to remove from the end of each line, such as the
+// semicolons ending statements in a generated list. They're removed after
+// trailing whitespace, along with any whitespace they uncover, so "a = 1; ;"
+// yields "a = 1". If trailing whitespace is kept by SetTrimTrailingSpace,
+// lines ending with whitespace are left as they are. Lines are wrapped before
+// the runes are removed. Defaults to "", which removes only whitespace.
+//
+// It's safe to call SetTrailingTrim between calls to ReadLine.
+func (s *Scanner) SetTrailingTrim(cutset string) {
+	s.cfg.TrailingTrim = cutset
+}
+
+// SetTrimLeadingAfterNewline sets whether whitespace at the start of each line
+// of input following a newline is discarded, such as to normalize indented
+// text. Whitespace at the start of the input is kept. Defaults to false, which
+// keeps leading whitespace as it's read.
+//
+// It's safe to call SetTrimLeadingAfterNewline between calls to ReadLine.
+func (s *Scanner) SetTrimLeadingAfterNewline(trim bool) {
+	s.cfg.TrimLeadingAfterNewline = trim
+}
+
+// SetReflow sets whether text is reflowed, joining lines within a paragraph
+// before wrapping as if they had been written on a single line. Paragraphs are
+// separated by blank lines, which are preserved. Indentation is kept on the
+// first line of a paragraph but dropped from the lines joined to it. Defaults
+// to false.
+//
+// It's safe to call SetReflow between calls to ReadLine.
+func (s *Scanner) SetReflow(enable bool) {
+	s.cfg.Reflow = enable
+}
+
+// SetDehyphenate sets whether words hyphenated across lines are rejoined when
+// reflowing. A line ending in a letter followed by a hyphen is joined to the
+// next without a space, and the hyphen is removed if the next line begins with
+// a lowercase letter. The hyphen is kept where the line looks to end partway
+// through a hyphenated compound: where the word already holds a hyphen, as in
+// "state-of-the-", or begins with a common first part of compounds, such as
+// "well-" or "self-". This is only a heuristic. A compound with any other first
+// part, such as "low-key" broken after its hyphen, is joined as "lowkey", while
+// a word continuing in uppercase or a digit keeps its hyphen. It has no effect
+// unless reflowing, and defaults to false.
+//
+// It's safe to call SetDehyphenate between calls to ReadLine.
+func (s *Scanner) SetDehyphenate(enable bool) {
+	s.cfg.Dehyphenate = enable
+}
+
+// SetDetectBullets sets whether lines of input beginning with a bullet marker
+// are laid out as list items, with lines continuing an item indented to align
+// with the text following its marker. A bullet marker is a word of its own,
+// followed by whitespace, which is one of "-", "*", "+", "•", "◦", "‣" or "⁃",
+// or a number of up to three digits followed by "." or ")", such as "1." or
+// "12)". When reflowing, a line beginning with a bullet marker starts a new
+// item rather than being joined to the line before it. The indent is dropped
+// if the text following the marker doesn't fit on the first line. It takes
+// precedence over SetAlignContinuationToTab. Defaults to false.
+//
+// It's safe to call SetDetectBullets between calls to ReadLine.
+func (s *Scanner) SetDetectBullets(enable bool) {
+	s.cfg.DetectBullets = enable
+}
+
+// SetLineMode sets whether each line of input is wrapped on its own, as for
+// logs or CSV, so every line of input yields its own run of output lines:
+// however many lines it wraps into, or one empty line if it's blank. Options
+// which treat lines as paragraphs are overridden while enabled: lines aren't
+// reflowed, no blank lines are added by SetMinParagraphGap, and lines aren't
+// consumed as directives. Defaults to false.
+//
+// It's safe to call SetLineMode between calls to ReadLine.
+func (s *Scanner) SetLineMode(enable bool) {
+	s.cfg.LineMode = enable
+}
+
+// SetNormalizeNewlines sets whether all common newline conventions are read as
+// a single "\n". These are CRLF ("\r\n"), a lone CR ("\r"), NEL (U+0085), and
+// the Unicode line and paragraph separators (U+2028 and U+2029), so a paragraph
+// separator no longer adds an empty line. If includeFormFeed is true, a form
+// feed ("\f") is also read as "\n". When disabled, the default, "\r" and "\f"
+// are treated as whitespace.
+//
+// It's safe to call SetNormalizeNewlines between calls to ReadLine.
+func (s *Scanner) SetNormalizeNewlines(enable, includeFormFeed bool) {
+	s.cfg.NormalizeNewlines = enable
+	s.cfg.NormalizeFormFeed = includeFormFeed
+}
+
+// SetTabWidth sets the width of tab characters. Tabs are expanded to spaces up
+// to the next multiple of width, measured from the start of the line's text. A
+// tab which extends past the limit, as when width exceeds the limit, is handled
+// like any other whitespace which doesn't fit: the line breaks and the tab is
+// dropped. A width of zero or less removes tabs from the output. A tab at the
+// start of a line of input is kept as indentation like other leading
+// whitespace, whether it begins the input or follows a newline.
 //
 // It's safe to call SetTabWidth between calls to ReadLine.
 func (s *Scanner) SetTabWidth(width int) {
-	s.tabWidth = width
+	if width <= 0 {
+		// Config reserves zero for the default width.
+		width = -1
+	}
+	s.cfg.TabWidth = width
+}
+
+// SetTabStops sets explicit tab stops, given as increasing columns measured from
+// the start of the line's text. A tab advances to the first stop past its
+// column; beyond the last stop, tabs advance to the next multiple of the tab
+// width as usual. Pass nil to use only the tab width.
+//
+// It's safe to call SetTabStops between calls to ReadLine.
+func (s *Scanner) SetTabStops(stops []int) {
+	s.cfg.TabStops = append([]int(nil), stops...)
+}
+
+// SetTabFixedWidth sets whether every tab is expanded to exactly the tab width
+// in spaces, wherever it begins, rather than to the next tab stop. By default,
+// a tab snaps to a stop, so "ab\tc" with a tab width of 4 is "ab  c", 5
+// columns wide; with fixed width tabs, it's "ab    c", 7 columns wide. Either
+// way, the output is measured as it's expanded, so lines never exceed the
+// limit. Fixed width tabs ignore the stops set with SetTabStops, but not the
+// function set with SetTabExpandToColumnFunc. Defaults to false.
+//
+// It's safe to call SetTabFixedWidth between calls to ReadLine.
+func (s *Scanner) SetTabFixedWidth(enable bool) {
+	s.cfg.TabFixedWidth = enable
+}
+
+// SetTabExpandToColumnFunc sets a function computing the column following a
+// tab which begins at the given column, measured from the start of the line's
+// text, giving full control over tab expansion. It overrides SetTabWidth and
+// SetTabStops, which it reproduces as special cases. A returned column at or
+// before the given one removes the tab. Pass nil to restore the tab width and
+// stops.
+//
+// It's safe to call SetTabExpandToColumnFunc between calls to ReadLine.
+func (s *Scanner) SetTabExpandToColumnFunc(fn func(col int) int) {
+	s.cfg.TabExpand = fn
+}
+
+// SetTabFillFunc sets a function rendering a tab expanded to the given width,
+// such as with an arrow followed by spaces, in place of spaces. The result must
+// be as wide as the given width. Pass nil to fill tabs with spaces.
+//
+// It's safe to call SetTabFillFunc between calls to ReadLine.
+func (s *Scanner) SetTabFillFunc(fn func(width int) string) {
+	s.cfg.TabFill = fn
+}
+
+// SetControlCharMode sets how control characters other than whitespace, such
+// as U+0001, DEL, or the C1 control U+0081, are rendered. U+0085 (NEL) is
+// whitespace, so it's left alone. The default is ControlCharPass.
+//
+// It's safe to call SetControlCharMode between calls to ReadLine.
+func (s *Scanner) SetControlCharMode(mode ControlCharMode) {
+	s.cfg.ControlCharMode = mode
+}
+
+// SetMaxLines sets the maximum number of lines returned. Once it's reached,
+// ReadLine returns io.EOF, and the remaining input is left unread beyond what's
+// needed to tell whether any lines were dropped. If lines are
+// dropped, the last line returned is marked with the suffix set by SetEllipsis
+// or SetTruncationSuffix, shortened as needed so the suffix fits within the
+// limit. The empty line standing for a trailing newline isn't counted as
+// dropped. A value of zero or less removes the limit, the default.
+//
+// It's safe to call SetMaxLines between calls to ReadLine. Lines already
+// returned count toward the maximum.
+func (s *Scanner) SetMaxLines(n int) {
+	s.cfg.MaxLines = n
+}
+
+// SetEllipsis sets the suffix marking the last line returned when lines are
+// dropped by SetMaxLines, such as "…". The line is shortened so that it and
+// the ellipsis fit the limit, and an ellipsis wider than the limit is cut.
+// Defaults to "", which leaves the last line as it is.
+//
+// It's safe to call SetEllipsis between calls to ReadLine.
+func (s *Scanner) SetEllipsis(ellipsis string) {
+	s.cfg.Ellipsis = ellipsis
+}
+
+// SetTruncationSuffix sets a function which computes the suffix marking the
+// last line returned when lines are dropped by SetMaxLines, overriding any set
+// by SetEllipsis. The function receives the number of lines dropped, such as
+// to produce "… (+12 more lines)". Counting them requires reading and laying
+// out all of the remaining input when the last line is returned, where it
+// would otherwise be left unread. The suffix is fitted to the limit as the
+// ellipsis is. Pass nil to use the ellipsis.
+//
+// It's safe to call SetTruncationSuffix between calls to ReadLine.
+func (s *Scanner) SetTruncationSuffix(f func(dropped int) string) {
+	s.cfg.TruncationSuffix = f
+}
+
+// SetUnicodeLineBreaks sets whether lines may break within runs of East Asian
+// text, which doesn't separate words with spaces, following the rules of
+// Unicode Standard Annex #14 for such text. Lines may then break between
+// ideographs and kana, and between them and other text, but not before closing
+// punctuation such as "。" or "」", nor after opening punctuation such as "「".
+// Defaults to false, in which case lines only break at whitespace.
+//
+// It's safe to call SetUnicodeLineBreaks between calls to ReadLine.
+func (s *Scanner) SetUnicodeLineBreaks(enable bool) {
+	s.cfg.UnicodeLineBreaks = enable
+}
+
+// SetLocale sets the language of the text as a BCP 47 tag, such as "ja-JP",
+// which selects line breaking rules tailored to it where available. It has no
+// effect unless SetUnicodeLineBreaks is enabled. Only Japanese is tailored: its
+// strict rules also forbid lines beginning with small kana, such as "ょ", or
+// the prolonged sound mark "ー". Other languages use the default rules, which
+// allow such breaks. Defaults to "".
+//
+// It's safe to call SetLocale between calls to ReadLine.
+func (s *Scanner) SetLocale(tag string) {
+	s.cfg.Locale = tag
+}
+
+// SetDebugWhitespace sets whether whitespace is made visible in the output, to
+// show exactly how lines were laid out and trimmed. Spaces are rendered as "·"
+// and the start of each expanded tab as "→", followed by "·" for the rest of
+// its width. Whitespace trimmed where a line wraps or ends is rendered as one
+// "×" per character at the end of the line, which may extend it past the
+// limit. Only the rendering changes; lines wrap as they otherwise would.
+// Defaults to false.
+//
+// It's safe to call SetDebugWhitespace between calls to ReadLine, though tabs
+// are marked from the next line to be laid out.
+func (s *Scanner) SetDebugWhitespace(enable bool) {
+	s.cfg.DebugWhitespace = enable
+}
+
+// SetMinParagraphGap sets the minimum number of blank lines separating
+// paragraphs. Where fewer blank lines separate two paragraphs of input, more are
+// added to the output. Blank lines at the start or end of input aren't between
+// paragraphs, so are left as they are. Setting n to 0 or 1 adds no lines, which
+// is the default. This pairs with SetReflow, under which a single blank line
+// ends each paragraph.
+//
+// It's safe to call SetMinParagraphGap between calls to ReadLine.
+func (s *Scanner) SetMinParagraphGap(n int) {
+	s.cfg.MinParagraphGap = n
+}
+
+// SetPreferSentenceBreaks sets whether lines prefer to break after the end of a
+// sentence. When a line must wrap, it breaks after the last word on it which
+// ends with ".", "!" or "?", optionally followed by closing quotes or brackets,
+// rather than after the last word which fits. This only takes effect when such a
+// word falls on the line before the point where it would otherwise break; a
+// line holding no sentence boundary breaks as usual. Each line of input is laid
+// out whole, as with break preferences, and the setting applies to MaxFit and
+// BreakBeforeShort but not MinRagged. Defaults to false.
+//
+// It's safe to call SetPreferSentenceBreaks between calls to ReadLine, though a
+// new setting takes effect from the next line of input.
+func (s *Scanner) SetPreferSentenceBreaks(enable bool) {
+	s.cfg.PreferSentenceBreaks = enable
+}
+
+// SetPrefixPlacement sets where the prefix is placed on a line aligned by a
+// directive, as set with SetDirectives. PrefixMargin places it at the start of
+// the line, before the padding which aligns the text, while PrefixHug places it
+// after the padding, immediately before the text. A continuation indent, as set
+// with SetAlignContinuationToTab, stays between the prefix and the text either
+// way. Defaults to PrefixMargin.
+//
+// It's safe to call SetPrefixPlacement between calls to ReadLine.
+func (s *Scanner) SetPrefixPlacement(placement PrefixPlacement) {
+	s.cfg.PrefixPlacement = placement
+}
+
+// SetMergePrefixPadding sets whether whitespace ending the prefix, along with
+// any gutter separator, merges into the padding of lines aligned to the center
+// or right by a directive, as set with SetDirectives, when the prefix is placed
+// with PrefixMargin. The padding then absorbs the whitespace rather than
+// following it, so no gap is doubled: with a prefix of "> ", a line padded by
+// seven columns follows ">" and seven spaces rather than eight, ending a column
+// short of the limit. Where the padding is narrower than the whitespace, as
+// for a line filling the limit, the gap is as wide as the whitespace. Defaults
+// to false.
+//
+// It's safe to call SetMergePrefixPadding between calls to ReadLine.
+func (s *Scanner) SetMergePrefixPadding(enable bool) {
+	s.cfg.MergePrefixPadding = enable
+}
+
+// SetAlignPrefixToTab sets whether the prefix, followed by any gutter separator,
+// is padded with spaces to the next tab stop, counted from the start of the
+// line, so text follows it on the same grid as tabbed content. A prefix ending
+// on a tab stop isn't padded. The padding doesn't count toward the limit.
+// Defaults to false.
+//
+// It's safe to call SetAlignPrefixToTab between calls to ReadLine.
+func (s *Scanner) SetAlignPrefixToTab(enable bool) {
+	s.cfg.AlignPrefixToTab = enable
+}
+
+// SetOnLimitChange sets a function called when a line is laid out at a
+// different limit than the line before it, as after SetLimit, such as to log
+// the change or recompute state depending on the limit. It's given the limit
+// of the line before and the limit of the new line. As the new limit applies
+// to text laid out after SetLimit, the call comes once the first line at the
+// new limit is laid out, which may be before it's returned. Pass nil to
+// disable the callback, the default.
+//
+// It's safe to call SetOnLimitChange between calls to ReadLine.
+func (s *Scanner) SetOnLimitChange(fn func(old, new int)) {
+	s.cfg.OnLimitChange = fn
+}
+
+// SetBreakAnywhere sets whether lines may break between any two characters,
+// like CSS's "word-break: break-all". Each line is filled with as much text as
+// fits, and a word which doesn't fit is broken wherever the line ends, even if
+// it would fit on a line of its own. Whitespace where a line breaks is dropped
+// as usual, so "hello world" wrapped to 3 columns yields "hel", "lo", "wor" and
+// "ld". Grapheme clusters are never split, so combining marks, flags, and emoji
+// sequences such as families stay whole. Break preferences and
+// SetMinWordsPerLine have no effect while it's enabled. Defaults to false.
+//
+// It's safe to call SetBreakAnywhere between calls to ReadLine.
+func (s *Scanner) SetBreakAnywhere(enable bool) {
+	s.cfg.BreakAnywhere = enable
+}
+
+// SetBreakHook sets a function which accepts or rejects each line break the
+// Scanner proposes before a word which doesn't fit within the limit, allowing
+// custom break rules without further options. The function is given the line
+// so far and the word, and returns true to break the line there. Returning
+// false rejects the break, so the word and its preceding whitespace overflow
+// the limit, and the next word which doesn't fit is proposed in turn. Breaks
+// forced within words too long for a line, breaks with SetBreakAnywhere, and
+// breaks chosen by MinRagged aren't proposed. A nil function, the default,
+// accepts every break.
+//
+// It's safe to call SetBreakHook between calls to ReadLine.
+func (s *Scanner) SetBreakHook(hook func(d ProposedBreak) (accept bool)) {
+	s.cfg.BreakHook = hook
+}
+
+// SetHyphenator sets a function returning the points at which a word may be
+// hyphenated, such as one applying Liang's algorithm with a dictionary. It's
+// consulted for each word too wide for a line, which must be broken. Each point
+// is a number of runes of the word preceding a permitted break, so 3 allows
+// "foobar" to break as "foo-" and "bar". The word is broken at the last point
+// leaving room for the hyphen, which is added to the end of the line; where no
+// point fits, it's broken wherever the line fills, as without a hyphenator.
+// Points at or beyond the ends of the word are ignored. It has no effect with
+// SetBreakAnywhere, which breaks words wherever lines fill. Pass nil to break
+// words without hyphens, the default.
+//
+// It's safe to call SetHyphenator between calls to ReadLine.
+func (s *Scanner) SetHyphenator(fn func(word string) []int) {
+	s.cfg.Hyphenator = fn
+}
+
+// SetStrictWidth sets whether a line wider than the limit is an error, as when
+// checking that text fits a fixed number of columns. Lines only exceed the
+// limit where text can't be broken to fit, such as a word kept whole by
+// SetKeepNumbers or SetHyphenationMinWordLen, or a character wider than the
+// limit. ReadLine and the methods built on it, such as WriteTo, then return a
+// *LineWidthError, wrapping ErrLineTooWide, in place of such a line. The line
+// is skipped, so reading may continue with the next. The prefix isn't counted,
+// as the limit excludes it. Defaults to false.
+//
+// It's safe to call SetStrictWidth between calls to ReadLine.
+func (s *Scanner) SetStrictWidth(enable bool) {
+	s.cfg.StrictWidth = enable
+}
+
+// SetQuoteAware sets whether spans quoted with double or single quotes are kept
+// whole, as when wrapping command lines or code. A quote opens a span at the
+// start of a word or following punctuation, as in --name="a b", but not
+// following a letter or digit, so apostrophes don't. The matching quote closes
+// the span unless escaped by a backslash. Spaces within the span don't separate
+// words, so the span and any text attached to it form a single word, which
+// begins the next line rather than being broken unless it's wider than the
+// limit. Tabs still separate words, and the end of a line of input closes any
+// span left open. Defaults to false.
+//
+// It's safe to call SetQuoteAware between calls to ReadLine.
+func (s *Scanner) SetQuoteAware(enable bool) {
+	s.cfg.QuoteAware = enable
+}
+
+// SetHyphenationMinWordLen sets the fewest runes a word must have to be broken,
+// with or without a hyphen, when it's too wide for a line. Shorter words
+// overflow the limit whole instead, as words kept by SetKeepNumbers do, so a
+// word a little too wide isn't chopped while a much longer one still is. It has
+// no effect with SetBreakAnywhere or SetVerbatim, which break words wherever
+// lines fill. Pass 0 to break every word too wide for a line, the default.
+//
+// It's safe to call SetHyphenationMinWordLen between calls to ReadLine.
+func (s *Scanner) SetHyphenationMinWordLen(n int) {
+	s.cfg.HyphenationMinWordLen = n
+}
+
+// SetNoLeadingFragment sets whether a line may begin with the rest of a word
+// broken on the line before only if the word is wider than the limit. Words no
+// wider than the limit are then never broken: with SetBreakAnywhere they begin
+// the next line instead of filling the end of the line, and on a line indented
+// to follow a tab or bullet, where less room is left, they overflow the limit
+// as words kept by SetKeepNumbers do. It has no effect with SetVerbatim, which
+// only breaks at the limit. Defaults to false.
+//
+// It's safe to call SetNoLeadingFragment between calls to ReadLine.
+func (s *Scanner) SetNoLeadingFragment(enable bool) {
+	s.cfg.NoLeadingFragment = enable
+}
+
+// SetVerbatim sets whether text is wrapped with its spacing kept exactly, as
+// for code where whitespace is significant. Lines break between any two
+// characters where they reach the limit, as with SetBreakAnywhere, but no
+// whitespace is ever removed: whitespace where a line breaks continues on the
+// next line, and whitespace at the end of a line of input is kept, wrapping as
+// needed. Tabs are still expanded. So "  foo   bar  " wrapped to 5 columns
+// yields "  foo", "   ba" and "r  ". Defaults to false.
+//
+// It's safe to call SetVerbatim between calls to ReadLine.
+func (s *Scanner) SetVerbatim(enable bool) {
+	s.cfg.Verbatim = enable
+}
+
+// SetCollectStats sets whether the Scanner counts the work it does, as reported
+// by Stats. Counting forced breaks and width lookups adds a little overhead, so
+// it's off by default. Counts of runes read and lines returned are always kept.
+//
+// It's safe to call SetCollectStats between calls to ReadLine. Counts collected
+// so far are kept when collection is disabled.
+func (s *Scanner) SetCollectStats(enable bool) {
+	s.cfg.CollectStats = enable
+	s.cfg.stats = nil
+	if enable {
+		s.cfg.stats = &s.stats
+	}
+}
+
+// SetRightMargin sets a number of columns to reserve at the end of each line,
+// such as for a border or scrollbar. Text wraps to the limit less the margin.
+// Unlike the prefix, which is excluded from the limit, the margin is taken from
+// it. Lines aligned by a directive are aligned short of the margin unless
+// SetAlignIntoRightMargin is enabled. Defaults to 0.
+//
+// It's safe to call SetRightMargin between calls to ReadLine.
+func (s *Scanner) SetRightMargin(n int) {
+	s.cfg.RightMargin = n
+}
+
+// SetAlignIntoRightMargin sets whether lines aligned by a directive may extend
+// into the margin set with SetRightMargin, so they're centered or right-aligned
+// within the whole limit. Text still wraps short of the margin. Defaults to
+// false.
+//
+// It's safe to call SetAlignIntoRightMargin between calls to ReadLine.
+func (s *Scanner) SetAlignIntoRightMargin(enable bool) {
+	s.cfg.AlignIntoRightMargin = enable
+}
+
+// SetKeepNumbers sets whether numbers are kept whole rather than broken to fit
+// the limit. A number is a run of digits which may be divided into groups by
+// periods, commas or colons, such as "1,234,567", "192.168.0.1" or "12:30",
+// with an optional leading sign and trailing punctuation. Digits of any script
+// are recognized, as are the Arabic decimal and thousands separators. A number
+// is still moved to the next line when it doesn't fit, but one wider than the
+// limit overflows it, as does a single character which is too wide. Defaults
+// to false.
+//
+// It's safe to call SetKeepNumbers between calls to ReadLine.
+func (s *Scanner) SetKeepNumbers(enable bool) {
+	s.cfg.KeepNumbers = enable
+}
+
+// SetKeepDigitGroups sets whether runs of digits, of any script, are kept
+// together when a word too long to fit is broken. The word breaks before the
+// run instead, and a run which begins the word is kept whole, overflowing the
+// limit if it's wider. Unlike SetKeepNumbers, this applies within words mixing
+// digits and letters, such as "build١٢٣٤". Defaults to false.
+//
+// It's safe to call SetKeepDigitGroups between calls to ReadLine.
+func (s *Scanner) SetKeepDigitGroups(enable bool) {
+	s.cfg.KeepDigitGroups = enable
+}
+
+// SetTruncateOverflow sets a hard cap of maxCols columns on each line returned by
+// ReadLine, including its prefix. Lines are wrapped as usual, but a line which
+// would still be wider, such as one holding a number kept whole by
+// SetKeepNumbers or a prefix wider than the cap, is cut short and ends with
+// ellipsis, which may be empty. The ellipsis is shortened too if it's wider
+// than the cap. This differs from SetMaxLines, which marks the last line
+// returned rather than each long line. A maxCols of 0 disables the cap, which
+// is the default.
+//
+// It's safe to call SetTruncateOverflow between calls to ReadLine.
+func (s *Scanner) SetTruncateOverflow(maxCols int, ellipsis string) {
+	s.cfg.OverflowLimit = maxCols
+	s.cfg.OverflowEllipsis = ellipsis
+}
+
+// SetColumnGuides sets whether each line is padded with spaces to the full
+// width of the limit, as for display in a fixed-width cell. The padding follows
+// the text, and any suffix, independently of alignment, which positions the
+// text within the limit. The prefix isn't counted, as it's excluded from the
+// limit, but the right margin set with SetRightMargin is padded. A line already
+// wider than the limit is left as it is. Blank lines are only padded if
+// SetPadBlankLines is enabled. Defaults to false.
+//
+// It's safe to call SetColumnGuides between calls to ReadLine.
+func (s *Scanner) SetColumnGuides(enable bool) {
+	s.cfg.ColumnGuides = enable
+}
+
+// SetPadBlankLines sets whether blank lines are padded to the limit along with
+// other lines when SetColumnGuides is enabled. The padding follows the prefix
+// if SetPrefixOnBlankLines is enabled. The empty line standing for a trailing
+// newline is never padded. Defaults to false.
+//
+// It's safe to call SetPadBlankLines between calls to ReadLine.
+func (s *Scanner) SetPadBlankLines(enable bool) {
+	s.cfg.PadBlankLines = enable
+}
+
+// SetFillToColumn sets a column, counted from the start of the text as the
+// limit is, to which each line with text is padded with fill, as for the dots
+// leading to page numbers in a table of contents. Unlike SetColumnGuides, the
+// fill follows the text directly, and any suffix, such as one set with
+// SetLastLineSuffix, follows the fill. A line already reaching col isn't
+// filled, and a wide fill stops short of col rather than passing it. A col of
+// 0 disables the fill, which is the default.
+//
+// It's safe to call SetFillToColumn between calls to ReadLine.
+func (s *Scanner) SetFillToColumn(col int, fill rune) {
+	s.cfg.FillColumn = col
+	s.cfg.FillRune = fill
+}
+
+// SetBreakOnUnderscore sets whether lines may break after underscores within
+// words, as in identifiers such as "some_long_identifier_name". The underscore
+// is kept at the end of the line. Lines don't break within a run of
+// underscores, nor after underscores beginning a word, so "__init__" is kept
+// whole. This combines with SetUnicodeLineBreaks, so a word may break at the
+// opportunities of either. Defaults to false.
+//
+// It's safe to call SetBreakOnUnderscore between calls to ReadLine.
+func (s *Scanner) SetBreakOnUnderscore(enable bool) {
+	s.cfg.BreakOnUnderscore = enable
+}
+
+// SetStreaming sets whether the Scanner guarantees to stream its input, holding
+// no more than the word being read and the lines it completes before they're
+// returned. Memory use is then bounded by the longest word rather than the
+// longest line of input. Options which lay out a whole line of input at once,
+// and so must buffer it, are rejected while streaming: break preferences other
+// than MaxFit, SetMinWordsPerLine with a value above 1, SetTabIsWordBreak with
+// false, and SetPreferSentenceBreaks. If any is set, ReadLine returns
+// ErrStreaming until it's unset. Defaults to false.
+//
+// It's safe to call SetStreaming between calls to ReadLine.
+func (s *Scanner) SetStreaming(enable bool) {
+	s.cfg.Streaming = enable
+}
+
+// SetDirectives sets whether directives in the text change the alignment of
+// paragraphs. A directive is a line of input holding only a period followed by
+// the name of an alignment: ".left", ".center" or ".right". It must begin a
+// paragraph, either at the start of input or following a blank line, and have
+// no whitespace before or after it on its line. The directive's line is removed
+// from the output, and each line of the paragraph following it is aligned
+// within the limit, until the next blank line restores left alignment. Lines
+// which aren't exactly a directive, such as ".centre" or " .center", are text.
+// Defaults to false.
+//
+// It's safe to call SetDirectives between calls to ReadLine.
+func (s *Scanner) SetDirectives(enable bool) {
+	s.cfg.Directives = enable
+}
+
+// SetTabIsWordBreak sets whether a tab between two words allows a line to
+// break there, as any other whitespace does. This is the default. When
+// disabled, words separated only by tabs, such as the label and value in
+// "name\tvalue", are kept together on one line, with the tabs still expanded
+// to align on tab stops. The line breaks between them only if they don't fit
+// on a line by themselves. Tabs adjacent to other whitespace, or beginning or
+// ending a line, are unaffected.
+//
+// Keeping words together considers a whole line of input at once, so while
+// disabled, ReadLine buffers each line of input until its end. It's safe to
+// call SetTabIsWordBreak between calls to ReadLine, though a new setting takes
+// effect from the start of the next line of input.
+func (s *Scanner) SetTabIsWordBreak(enable bool) {
+	s.cfg.TabJoinsWords = !enable
+}
+
+// SetAlignContinuationToTab sets whether lines continuing a wrapped line of
+// input are indented to align with the text following its first tab, as in the
+// two-column layout of a man page's option list. The indent is the column after
+// the tab, provided the tab and the word following it fall on the first line;
+// otherwise, continuation lines aren't indented. Words too long to fit after
+// the indent are broken to fit. Defaults to false.
+//
+// It's safe to call SetAlignContinuationToTab between calls to ReadLine, though
+// it takes effect from the next tab read.
+func (s *Scanner) SetAlignContinuationToTab(enable bool) {
+	s.cfg.AlignContinuationToTab = enable
+}
+
+// SetReplaceNBSPWithSpace sets whether no-break spaces (U+00A0) are emitted as
+// regular spaces. This only affects the output; it doesn't change where lines
+// may be broken. No-break spaces never break a line, and are laid out as part
+// of the surrounding word. Defaults to false.
+//
+// It's safe to call SetReplaceNBSPWithSpace between calls to ReadLine.
+func (s *Scanner) SetReplaceNBSPWithSpace(enable bool) {
+	s.cfg.ReplaceNBSPWithSpace = enable
+}
+
+// SetBreakPreference sets the heuristic used to choose where lines break. The
+// default, MaxFit, places as many words as fit on each line. Other preferences
+// consider a whole line of input at once, so ReadLine buffers each line of input
+// until its end before returning any of it.
+//
+// It's safe to call SetBreakPreference between calls to ReadLine, though a new
+// preference takes effect from the start of the next line of input.
+func (s *Scanner) SetBreakPreference(pref BreakPreference) {
+	s.cfg.BreakPreference = pref
+}
+
+// SetGapChar sets a rune which renders whitespace between words that doesn't
+// come from the input as read: the expansion of each tab, and the space
+// joining lines with SetReflow. This allows a renderer to use a thin space, or
+// a visible mark when debugging. Other whitespace is kept as read. The rune
+// must be one column wide. Pass 0 to use spaces, the default.
+//
+// It's safe to call SetGapChar between calls to ReadLine.
+func (s *Scanner) SetGapChar(char rune) {
+	s.cfg.GapChar = char
+}
+
+// SetReplacer sets a replacer applied to each word as it's read, such as to map
+// smart quotes to ASCII. Words are measured and wrapped as replaced, so a
+// replacement changing a word's width is accounted for. Replacements apply
+// within a word only, never across the whitespace between words. A replaced
+// word is mapped to its source as a whole, so ReadLineMapped reports the end
+// of the word for any part of it. ReadLogicalLine returns text as read. Pass
+// nil to leave words as read, the default.
+//
+// It's safe to call SetReplacer between calls to ReadLine. It applies to words
+// read afterward.
+func (s *Scanner) SetReplacer(r *strings.Replacer) {
+	s.cfg.Replacer = r
+}
+
+// SetMaskChar sets a rune which replaces each rune of output other than
+// whitespace, as when displaying a password. Lines wrap exactly as they would
+// for the original text, so the mask reveals the length of each word. Pass 0
+// to disable masking, the default.
+//
+// It's safe to call SetMaskChar between calls to ReadLine. The mask applies to
+// every line returned afterward, including lines returned by ReadLogicalLine.
+func (s *Scanner) SetMaskChar(mask rune) {
+	s.cfg.MaskChar = mask
+}
+
+// SetAmbiguousWidth sets the number of columns, 1 or 2, occupied by characters
+// whose East Asian width is ambiguous, such as Greek letters and some
+// punctuation. Terminals configured for CJK text typically display these as
+// two columns. Other values are treated as 1, the default.
+//
+// It's safe to call SetAmbiguousWidth between calls to ReadLine.
+func (s *Scanner) SetAmbiguousWidth(width int) {
+	s.cfg.AmbiguousWidth = width
+}
+
+// SetMinWordsPerLine sets the minimum number of words preferred on each line
+// other than the last of a paragraph. When a line would hold fewer, words are
+// moved down to it from the end of the line before, provided they fit and that
+// line keeps at least n words of its own. Lines which begin with a piece of a
+// word too long to fit are left alone, as nothing can be moved before them.
+// Values below 2 disable this, the default.
+//
+// Like break preferences other than MaxFit, this considers a whole line of
+// input at once, so ReadLine buffers each line of input until its end. It's
+// safe to call SetMinWordsPerLine between calls to ReadLine, though a new value
+// takes effect from the start of the next line of input.
+func (s *Scanner) SetMinWordsPerLine(n int) {
+	s.cfg.MinWordsPerLine = n
+}
+
+// SetErrorHandler sets a function which is called when reading from the
+// underlying reader fails with an error other than io.EOF. If the function
+// returns nil, the read is retried; otherwise, the returned error is reported
+// and reading stops, as it does for all errors when no handler is set. The
+// handler is called again each time a retried read fails, so it must
+// eventually return an error for a reader which never recovers. A reader which
+// repeatedly returns no input and no error fails with io.ErrNoProgress, which
+// isn't passed to the handler. Pass nil to remove the handler.
+//
+// It's safe to call SetErrorHandler between calls to ReadLine.
+func (s *Scanner) SetErrorHandler(f func(err error) error) {
+	s.cfg.ErrorHandler = f
 }
 
 // ReadLine reads a single wrapped line, not including end-of-line characters
-// ("\n"). Trailing newlines are preserved. At EOF, the result will be an empty
-// string and the error will be io.EOF.
+// ("\n"). Trailing newlines are preserved: each newline ends a line, so input
+// ending in n newlines yields n lines after its last text, all empty. For
+// example, "foo" yields only "foo", while "foo\n\n" yields "foo", "", and "".
+// Text ending without a newline never yields an empty line, even if it fills
+// the limit exactly: at a limit of 4, "abcd" yields "abcd", "abcde" yields
+// "abcd" and "e", and "abcdef" yields "abcd" and "ef". At EOF, the result will
+// be an empty string and the error will be io.EOF. If reading input fails, the
+// error is a *WrapError wrapping the reader's error, which is returned again by
+// each later call.
 //
 // ReadLine always attempts to return at least one line, even on empty input.
 //
 // ReadLine attempts to handle tab characters gracefully, converting them to
 // spaces aligned on the boundary define in SetTabWidth.
 func (s *Scanner) ReadLine() (string, error) {
-	if s.err != nil {
-		return "", s.err
+	line, err := s.nextLine()
+	if err != nil {
+		return "", err
 	}
+	return s.decorate(line, s.linePrefix(line)), nil
+}
 
-	for {
-		var char rune
-		char, _, err := s.r.ReadRune()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			s.err = err
-			return "", err
-		}
+// ReadLinePrefixed is like ReadLine, but applies the given prefix to the line
+// in place of the prefix set with SetPrefix or SetPrefixFunc. This allows a
+// caller to supply each line's prefix, such as an annotation in a gutter, as
+// the line is read. The prefix is subject to the same rules as SetPrefix, so
+// it's not applied to empty lines unless SetPrefixOnBlankLines is enabled.
+func (s *Scanner) ReadLinePrefixed(prefix string) (string, error) {
+	line, err := s.nextLine()
+	if err != nil {
+		return "", err
+	}
+	return s.decorate(line, func() string { return prefix }), nil
+}
 
-		if unicode.IsSpace(char) {
-			if _, err := s.flushWord(); err != nil {
-				s.err = err
-				return "", err
-			}
+// ReadLineMapped is like ReadLine, but also returns the range of bytes of input
+// from which the line was laid out, as offsets from the first byte the Scanner
+// read. This allows a position in wrapped text to be mapped back to the input,
+// as when placing a cursor.
+//
+// The range covers the line's text, including any indentation kept at its
+// start, but not whitespace trimmed where it wraps or ends, nor the newline
+// ending it. The text of consecutive lines thus covers the input in order, with
+// only whitespace left between their ranges. A word broken across lines has its
+// range divided between them, and a line joined from several lines of input by
+// SetReflow covers the newlines between them. An empty line has an empty range
+// at the position where it ends.
+func (s *Scanner) ReadLineMapped() (text string, sourceStart, sourceEnd int, err error) {
+	line, err := s.nextLine()
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return s.decorate(line, s.linePrefix(line)), line.start, line.end, nil
+}
 
-			if char == '\n' {
-				ret := s.line.String()
-				s.skipNextWS = false
-				s.line.Reset()
-				s.space.Reset()
-				return ret, nil
-			}
+// nextLine returns the next line to be returned, applying the line limit.
+func (s *Scanner) nextLine() (pendingLine, error) {
+	if s.cfg.Streaming && s.cfg.buffers() {
+		return pendingLine{}, ErrStreaming
+	}
+	if s.cfg.MaxLines > 0 && s.lineNum >= s.cfg.MaxLines {
+		return pendingLine{}, io.EOF
+	}
 
-			if s.skipNextWS {
-				continue
-			}
+	line, err := s.takeLine()
+	if err != nil {
+		return line, err
+	}
+	if s.cfg.MaxLines > 0 && s.lineNum+1 == s.cfg.MaxLines {
+		line, err = s.truncate(line)
+		line.last = true
+	} else {
+		err = s.markLast(&line)
+	}
+	if err == ErrNeedMore {
+		// The line is taken again once more input is fed.
+		s.lines = append([]pendingLine{line}, s.lines...)
+	}
+	if err == nil && s.cfg.StrictWidth {
+		err = s.checkWidth(line)
+	}
+	return line, err
+}
+
+// checkWidth returns a *LineWidthError if the line is wider than the limit,
+// counting it as returned so the next line is numbered after it.
+func (s *Scanner) checkWidth(line pendingLine) error {
+	width := line.indent + s.cfg.stringWidth(line.text) + s.cfg.stringWidth(line.suffix)
+	if width <= s.textLimit() {
+		return nil
+	}
+	s.lineNum++
+	return &LineWidthError{Line: s.lineNum, Text: line.text, Width: width, Limit: s.textLimit()}
+}
 
-			if char == '\t' {
-				// Replace tabs with spaces while preserving alignment.
-				count := 0
-				if width := s.tabWidth; width != 0 {
-					count = width - s.line.Count()%width
-				}
-				s.space.WriteString(strings.Repeat(" ", count))
-			} else {
-				if _, err := s.space.WriteRune(char); err != nil {
-					s.err = err
-					return "", err
-				}
+// takeLine scans until a line is laid out, then removes and returns it.
+func (s *Scanner) takeLine() (pendingLine, error) {
+	if err := s.fill(0); err != nil {
+		return pendingLine{}, err
+	}
+	line := s.lines[0]
+	s.lines = s.lines[1:]
+	return line, nil
+}
+
+// fill scans until the line at index i of the pending lines is laid out,
+// unless it already is.
+func (s *Scanner) fill(i int) error {
+	for len(s.lines) <= i {
+		if s.err != nil {
+			return s.err
+		}
+		if err := s.scan(); err != nil {
+			if err == ErrNeedMore {
+				return err
 			}
-		} else {
-			s.word.WriteRune(char)
-			s.skipNextWS = false
-			if s.needNewline {
-				ret := s.line.String()
-				s.needNewline = false
-				s.line.Reset()
-				return ret, nil
+			if err != io.EOF {
+				err = s.readError(err, s.pendingText())
 			}
-		}
-
-		// Commit the line if we've reached the maximum width.
-		if s.line.Count()+s.word.Count()+s.space.Count() >= s.limit {
-			//fmt.Println(s.lineChars, s.spaceChars, s.line.String()+s.space.String())
-			next, nextSize, err := peekRune(s.r)
-			if err != nil && err != io.EOF {
-				s.err = err
-				return "", err
+			s.err = err
+			if err != io.EOF {
+				return err
 			}
+		}
+	}
+	return nil
+}
 
-			// Flush if the next character constitutes a word break.
-			if s.word.Count() == s.limit || unicode.IsSpace(next) || nextSize == 0 {
-				if _, err := s.flushWord(); err != nil {
-					s.err = err
-					return "", err
-				}
-			}
+// ReadLogicalLine reads the remainder of the current line of input, up to an
+// explicit newline or EOF, without wrapping it. This allows a caller to display
+// wrapped text while retaining the original line, such as for copying.
+//
+// The text is returned as read, without a prefix or tab expansion, and with its
+// trailing newline removed. Text already returned by ReadLine isn't repeated.
+// If ReadLine has read ahead, its pending text is included as it would have
+// been returned, with wrapped lines rejoined by a single space.
+//
+// As with ReadLine, at EOF the result will be an empty string and the error
+// will be io.EOF. The mask set with SetMaskChar applies here too, as does
+// SetDebugWhitespace.
+func (s *Scanner) ReadLogicalLine() (string, error) {
+	line, err := s.readLogicalLine()
+	if s.cfg.MaskChar != 0 {
+		line = s.mask(line)
+	}
+	if s.cfg.DebugWhitespace {
+		line = showWhitespace(line)
+	}
+	return line, err
+}
 
-			if nextSize != 0 && next != '\n' && s.space.Count() < s.limit {
-				// We had some non-whitespace chars, so start a new line for the next write.
-				s.needNewline = true
-			}
+// readLogicalLine implements ReadLogicalLine without masking.
+func (s *Scanner) readLogicalLine() (string, error) {
+	if f, ok := s.r.(*feedReader); ok && !s.hasLogicalEnd() && !f.hasLine(&s.cfg) {
+		return "", ErrNeedMore
+	}
+	var b strings.Builder
+	for len(s.lines) > 0 {
+		line := s.lines[0]
+		s.lines = s.lines[1:]
+		b.WriteString(line.text)
 
-			s.skipNextWS = true
-			s.space.Reset()
+		switch line.brk {
+		case breakSoft:
+			b.WriteByte(' ')
+		case breakHard, breakEOF:
+			return b.String(), nil
 		}
 	}
-
-	if _, err := s.flushWord(); err != nil {
-		s.err = err
-		return "", err
+	if s.err != nil {
+		return "", s.err
 	}
 
-	ret := s.line.String()
+	b.WriteString(s.pendingText())
 	s.line.Reset()
-	s.err = io.EOF
-	return ret, nil
+	s.lineWidth = 0
+	s.indent, s.lineIndent, s.aligned = 0, 0, false
+	s.lineMapped = false
+	s.para = s.para[:0]
+	s.space.Reset()
+	s.resetWord()
+
+	for {
+		char, err := s.readRune()
+		if err == io.EOF {
+			s.err = err
+			return b.String(), nil
+		} else if err != nil {
+			s.err = s.readError(err, b.String())
+			return "", s.err
+		}
+
+		switch char {
+		case '\n', lineSeparator:
+			return b.String(), nil
+		case paragraphSeparator:
+			s.lines = append(s.lines, pendingLine{brk: breakHard})
+			return b.String(), nil
+		}
+		b.WriteRune(char)
+	}
 }
 
 // WriteTo implements io.WriterTo. This may make multiple calls to the Read
 // method of the underlying Reader.
+//
+// The output is exactly the lines returned by ReadLine joined by "\n", with no
+// newline after the last line. Since input ending in a newline yields a final
+// empty line from ReadLine, such input produces output ending in a newline,
+// and input without a trailing newline produces output without one. With
+// SetHTMLOutput, each line is escaped and they're joined by an HTML line break.
 func (s *Scanner) WriteTo(w io.Writer) (n int64, err error) {
 	firstLine := true
 	newline := []byte("\n")
+	if s.cfg.HTMLOutput {
+		newline = []byte(s.cfg.htmlLineBreak())
+	}
 	for {
 		line, err := s.ReadLine()
 		if err == io.EOF {
@@ -172,6 +1178,9 @@ func (s *Scanner) WriteTo(w io.Writer) (n int64, err error) {
 		}
 
 		if !firstLine {
+			if max := s.cfg.MaxOutputBytes; max > 0 && n+int64(len(newline)) > max {
+				return n, ErrOutputLimit
+			}
 			written, err := w.Write(newline)
 			n += int64(written)
 			if err != nil {
@@ -179,49 +1188,532 @@ func (s *Scanner) WriteTo(w io.Writer) (n int64, err error) {
 			}
 		}
 
-		written, err := io.WriteString(w, line)
+		out, full := s.outputText(line), true
+		if max := s.cfg.MaxOutputBytes; max > 0 && n+int64(len(out)) > max {
+			out, full = s.cutOutput(line[:s.leadBytes], line[s.leadBytes:], int(max-n)), false
+		}
+		written, err := io.WriteString(w, out)
 		n += int64(written)
 		if err != nil {
 			return n, err
 		}
+		if !full {
+			return n, ErrOutputLimit
+		}
 
 		firstLine = false
 	}
 }
 
-func (s *Scanner) flushWord() (int, error) {
-	var written int
-	if s.word.Count() > 0 {
-		if s.line.Count() == 0 {
-			n, err := s.line.WriteString(s.prefix)
-			written += n
-			if err != nil {
-				return written, err
+// SetMaxOutputBytes sets the maximum number of bytes WriteTo, and so Drain,
+// writes, as when filling a buffer of fixed size. Once the next line or newline
+// wouldn't fit, WriteTo writes as much of the line as fits and returns
+// ErrOutputLimit. The line is cut between runes, never within one, and neither
+// its prefix nor, with SetHTMLOutput, an escaped character is cut: either fits
+// whole or is left out. The count WriteTo returns is thus at most n. Pass 0 to
+// write without limit, the default.
+//
+// It's safe to call SetMaxOutputBytes between calls to ReadLine. Each call to
+// WriteTo counts its own output.
+func (s *Scanner) SetMaxOutputBytes(n int64) {
+	s.cfg.MaxOutputBytes = n
+}
+
+// SetHTMLOutput sets whether WriteTo, and so Drain, writes its output as HTML
+// text for embedding in a page: "&", "<" and ">" are escaped in each line, and
+// lines are separated by the line break set with SetHTMLLineBreak rather than
+// "\n". Lines returned by ReadLine aren't affected. Defaults to false.
+//
+// It's safe to call SetHTMLOutput between calls to ReadLine.
+func (s *Scanner) SetHTMLOutput(enable bool) {
+	s.cfg.HTMLOutput = enable
+}
+
+// SetHTMLLineBreak sets the separator between lines written as HTML, as set
+// with SetHTMLOutput. It's written as given, without escaping. An empty
+// separator selects the default, "<br>\n".
+//
+// It's safe to call SetHTMLLineBreak between calls to ReadLine.
+func (s *Scanner) SetHTMLLineBreak(br string) {
+	s.cfg.HTMLLineBreak = br
+}
+
+// WriteToHash is like WriteTo, but also writes the output to h so its checksum
+// can be computed without a second pass. The hash is not reset beforehand.
+func (s *Scanner) WriteToHash(w io.Writer, h hash.Hash) (int64, error) {
+	return s.WriteTo(io.MultiWriter(w, h))
+}
+
+// Drain reads all remaining lines and returns them joined by newlines, exactly
+// as WriteTo would write them.
+func (s *Scanner) Drain() (string, error) {
+	var buf bytes.Buffer
+	_, err := s.WriteTo(&buf)
+	return buf.String(), err
+}
+
+// ReadAll reads all remaining lines and returns them separately, as ReadLine
+// would return them. If reading fails, it returns the lines read before the
+// failure along with the error; EOF isn't an error.
+func (s *Scanner) ReadAll() ([]string, error) {
+	var lines []string
+	for {
+		line, err := s.ReadLine()
+		if err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+// LineStarts reads all remaining lines and returns the offset in the input at
+// which each begins, as ReadLineMapped reports it, rather than the lines. With
+// the offsets, a caller rendering a window of lines, such as in a terminal,
+// can seek the input to the window's first line and wrap from there without
+// wrapping the lines before it again. Wrapping resumes as it would have
+// continued provided the line options don't depend on earlier lines, as they
+// don't by default. If reading fails, it returns the offsets of the lines read
+// before the failure along with the error; EOF isn't an error.
+func (s *Scanner) LineStarts() ([]int, error) {
+	var starts []int
+	for {
+		_, start, _, err := s.ReadLineMapped()
+		if err == io.EOF {
+			return starts, nil
+		} else if err != nil {
+			return starts, err
+		}
+		starts = append(starts, start)
+	}
+}
+
+// Unwrap returns the reader from which the Scanner reads. This is the reader
+// given to NewScanner unless it was wrapped to buffer it, in which case any
+// buffered data stays with the returned reader. Input which the Scanner has
+// read but not yet returned, such as a partial line, is not included; the
+// remainder of a partial line can be retrieved with ReadLogicalLine.
+//
+// Reading from the returned reader consumes input which the Scanner would
+// otherwise read.
+func (s *Scanner) Unwrap() io.Reader {
+	return s.r
+}
+
+// readRune reads a single rune of input, normalizing newlines if enabled.
+func (s *Scanner) readRune() (rune, error) {
+	if err := s.readErr; err != nil {
+		s.readErr = nil
+		return 0, err
+	}
+
+	char, err := s.readRaw()
+	if err != nil || !s.cfg.NormalizeNewlines {
+		return char, err
+	}
+
+	switch char {
+	case '\r':
+		// The reader may not repeat an error, so hold it for the next read.
+		// io.EOF is left for the reader to return again.
+		next, err := s.readRaw()
+		switch {
+		case err == nil && next != '\n':
+			s.r.UnreadRune()
+			s.offset -= s.lastSize
+			s.runes--
+		case err == ErrNeedMore:
+			// Whether a newline follows isn't known yet, so the carriage
+			// return is read again once it is.
+			s.r.UnreadRune()
+			s.offset--
+			s.runes--
+			return 0, err
+		case err != nil && err != io.EOF:
+			s.readErr = err
+		}
+		return '\n', nil
+	case '\u0085', lineSeparator, paragraphSeparator:
+		return '\n', nil
+	case '\f':
+		if s.cfg.NormalizeFormFeed {
+			return '\n', nil
+		}
+	}
+	return char, nil
+}
+
+// readRaw reads a single rune from the underlying reader. Read errors other than
+// io.EOF are passed to the error handler, if any, and the read is retried while
+// it returns nil.
+func (s *Scanner) readRaw() (rune, error) {
+	if s.note.Len() > 0 {
+		// Footnote text isn't part of the source, so it takes no space there.
+		char, _, _ := s.note.ReadRune()
+		s.lastSize = 0
+		return char, nil
+	}
+	for empty := 0; ; {
+		char, size, err := s.r.ReadRune()
+		if size == 0 && err == nil {
+			// A read which consumes nothing is retried, but not forever.
+			if empty++; empty == maxEmptyReads {
+				return 0, io.ErrNoProgress
 			}
+			continue
+		}
+		s.offset += size
+		s.lastSize = size
+		if size > 0 {
+			s.runes++
+		}
+		if err == nil || err == io.EOF || err == ErrNeedMore || s.cfg.ErrorHandler == nil {
+			return char, err
 		}
+		if err := s.cfg.ErrorHandler(err); err != nil {
+			return 0, err
+		}
+	}
+}
 
-		n, err := s.space.WriteTo(&s.line)
-		written += int(n)
-		if err != nil {
-			return written, err
+// scan reads and processes a single rune of input.
+func (s *Scanner) scan() error {
+	s.runeStart = s.offset
+	char, err := s.readRune()
+	if err == io.EOF {
+		if s.joinPending {
+			s.joinPending = false
+			s.endLine(breakHard)
 		}
+		s.directive()
+		s.endLine(breakEOF)
+		return err
+	} else if err != nil {
+		return err
+	}
 
-		n, err = s.word.WriteTo(&s.line)
-		written += int(n)
-		if err != nil {
-			return written, err
+	if char == s.noteMarker && s.notes != nil && s.note.Len() == 0 {
+		if ok, err := s.openFootnote(); err != nil || ok {
+			return err
 		}
 	}
-	return written, nil
+
+	if s.joinPending && s.resolveNewline(char) {
+		return nil
+	}
+
+	if isControl(char) {
+		switch s.cfg.ControlCharMode {
+		case ControlCharStrip:
+			if s.word.Count() > 0 {
+				s.wordEdited = true
+			}
+			return nil
+		case ControlCharCaret:
+			if char >= 0x80 {
+				s.writeWord('M', s.runeStart)
+				s.writeWord('-', s.runeStart)
+				char -= 0x80
+			}
+			s.writeWord('^', s.runeStart)
+			s.wordEdited = true
+			char ^= 0x40
+		}
+	}
+
+	switch {
+	case char == '\n' && s.directive():
+		// The directive line is consumed.
+	case char == '\n' && s.cfg.Reflow && !s.cfg.LineMode && s.hasContent():
+		s.holdNewline()
+	case char == '\n', char == lineSeparator:
+		s.endLine(breakHard)
+	case char == paragraphSeparator:
+		s.endLine(breakHard)
+		s.endLine(breakHard)
+	case isBreakingSpace(char) && s.afterNewline && s.cfg.TrimLeadingAfterNewline:
+		// Leading whitespace is discarded.
+	case isBreakingSpace(char) && char != '\t' && s.quote != 0:
+		// Spaces within quotes join the quoted span into a single word.
+		s.writeWord(char, s.offset)
+	case isBreakingSpace(char):
+		glue := char == '\t' && s.cfg.TabJoinsWords && (s.word.Count() > 0 || s.glue)
+		s.endWord()
+		s.glue = glue
+		s.writeSpace(char)
+	default:
+		if s.word.Count() > 0 && s.quote == 0 && s.breaksBefore(char) {
+			// The word ends here, though no whitespace separates it from the next.
+			s.endWord()
+		}
+		s.afterNewline = false
+		if s.cfg.QuoteAware {
+			s.trackQuote(char)
+		}
+		s.writeWord(char, s.offset)
+	}
+	return nil
 }
 
-func peekRune(r io.RuneScanner) (rune, int, error) {
-	ch, size, err := r.ReadRune()
-	if err != nil {
-		return ch, size, err
+// endWord lays out the word being read along with its preceding whitespace.
+func (s *Scanner) endWord() {
+	if s.word.Count() == 0 {
+		return
+	}
+
+	it := s.sourceItem()
+	it.quoted = s.wordQuoted
+	s.space.Reset()
+	s.resetWord()
+
+	if s.joined {
+		s.joined = false
+		if s.cfg.DetectBullets && isBullet(it.text) {
+			// The item begins a line of its own rather than continuing the
+			// line joined to it.
+			it.gap, it.gapStart = "", it.start
+			s.endLine(breakHard)
+		}
+	}
+	if s.lineWords == 0 {
+		s.bullet = s.cfg.DetectBullets && isBullet(it.text)
+	}
+	s.lineWords++
+
+	if s.blankRun > 0 {
+		s.separateParagraph()
+	}
+	if s.line.Count() == 0 && len(s.para) == 0 {
+		// A new break preference takes effect at the start of a line of input.
+		s.linePref = s.cfg.BreakPreference
+		s.lineMin = s.cfg.MinWordsPerLine
+		s.lineGlue = s.cfg.TabJoinsWords
+		s.lineSentence = s.cfg.PreferSentenceBreaks
+	}
+	it.glued = s.glue && s.lineGlue
+	s.glue = false
+	if (s.cfg.BreakAnywhere || s.cfg.Verbatim) && len(s.para) == 0 && !s.keepWhole(it) {
+		s.alignBullet(it)
+		s.alignTab(it)
+		s.placeAnywhere(it)
+		return
+	}
+	if s.linePref != MaxFit || s.lineMin > 1 || s.lineGlue || s.lineSentence {
+		s.para = append(s.para, it)
+		return
+	}
+
+	s.alignBullet(it)
+	s.alignTab(it)
+	if limit := s.textLimit() - s.indent; it.width > limit {
+		for _, piece := range s.splitLong(nil, []item{it}, limit) {
+			s.place(piece)
+		}
+		return
+	}
+	s.place(it)
+}
+
+// endLine completes the current line of input, discarding trailing whitespace
+// unless it's to be kept.
+func (s *Scanner) endLine(brk lineBreak) {
+	// A blank line ends a paragraph.
+	s.inPara = s.hasContent()
+	s.endWord()
+	s.glue = false
+	s.quote, s.quoteEscaped = 0, false
+	if len(s.para) > 0 {
+		s.layoutParagraph(s.para)
+		s.para = s.para[:0]
 	}
-	if err := r.UnreadRune(); err != nil {
-		return 0, 0, err
+	if s.cfg.Verbatim {
+		s.placeSpace(s.space.String(), s.spaceStart)
+	} else if s.cfg.KeepTrailingSpace {
+		s.placeTrailing(s.space.String())
+	} else {
+		s.lineTrim = s.space.Count()
 	}
-	return ch, size, nil
+	s.space.Reset()
+	s.breakLine(brk)
+	switch {
+	case s.inPara:
+		s.seenText = true
+	case brk != breakEOF:
+		s.blankRun++
+	}
+	if !s.inPara {
+		s.align = AlignLeft
+	}
+	s.afterNewline = true
+	s.lineWords, s.bullet = 0, false
+}
+
+// separateParagraph ends a run of blank lines as a paragraph begins, adding
+// blank lines to it as needed to meet the minimum gap between paragraphs.
+func (s *Scanner) separateParagraph() {
+	if s.seenText && !s.cfg.LineMode {
+		for n := s.blankRun; n < s.cfg.MinParagraphGap; n++ {
+			s.breakLine(breakHard)
+		}
+	}
+	s.blankRun = 0
+}
+
+// textLimit returns the number of columns available to text on each line.
+func (s *Scanner) textLimit() int {
+	limit := s.cfg.Limit - s.cfg.RightMargin
+	if s.cfg.GutterSeparatorCountsTowardLimit {
+		limit -= s.cfg.stringWidth(s.cfg.GutterSeparator)
+	}
+	if s.cfg.PrefixCountsTowardLimit && s.cfg.PrefixFunc == nil {
+		limit -= s.cfg.stringWidth(s.cfg.expandPrefix(s.cfg.Prefix))
+	}
+	if limit < 1 {
+		return 1
+	}
+	return limit
+}
+
+// decorate returns a laid out line with a prefix applied. The prefix is only
+// computed if it's needed, and is expanded with lead.
+func (s *Scanner) decorate(line pendingLine, prefix func() string) string {
+	s.lineNum++
+	trimmed := 0
+	if s.cfg.DebugWhitespace {
+		trimmed = line.trimmed
+	}
+	if line.text == "" && line.suffix == "" && trimmed == 0 {
+		// The empty line at EOF stands for a trailing newline, so it's left bare.
+		if line.brk == breakEOF {
+			s.leadBytes = 0
+			return ""
+		}
+		lead := ""
+		if s.cfg.PrefixOnBlankLines {
+			lead = s.cfg.lead(prefix())
+			if s.cfg.TrimPrefixOnBlank {
+				lead = strings.TrimRightFunc(lead, unicode.IsSpace)
+			}
+		}
+		if s.cfg.ColumnGuides && s.cfg.PadBlankLines {
+			lead += s.guide(0)
+		}
+		lead = s.capWidth(lead)
+		s.leadBytes = len(lead)
+		return lead
+	}
+	lead := s.cfg.lead(prefix())
+	if s.cfg.PrefixPlacement == PrefixHug {
+		lead = s.render(strings.Repeat(" ", line.pad)) + lead
+	} else {
+		if s.cfg.MergePrefixPadding && line.align != AlignLeft {
+			// The padding absorbs the prefix's whitespace, only leaving a gap
+			// as wide where it's narrower.
+			trimmed := strings.TrimRightFunc(lead, unicode.IsSpace)
+			ws := s.cfg.stringWidth(lead) - s.cfg.stringWidth(trimmed)
+			lead, line.pad = trimmed, maxInt(line.pad, ws)
+		}
+		line.indent += line.pad
+		line.pad = 0
+	}
+	text := s.render(strings.Repeat(" ", line.indent) + line.text)
+	text += strings.Repeat("×", trimmed)
+	if s.cfg.FillColumn > 0 {
+		text += s.fillTo(line.pad + s.cfg.stringWidth(text))
+	}
+	text += line.suffix
+	if line.last && s.cfg.LastLine != LastLineNone {
+		text += s.cfg.LastLineSuffix
+	}
+	if s.cfg.ColumnGuides {
+		text += s.guide(line.pad + s.cfg.stringWidth(text))
+	}
+	out := s.capWidth(lead + text)
+	s.leadBytes = len(lead)
+	if len(out) < len(lead) {
+		s.leadBytes = len(out)
+	}
+	return out
+}
+
+// fillTo returns the fill which pads a line with text of the given width out to
+// the fill column, as set with SetFillToColumn.
+func (s *Scanner) fillTo(width int) string {
+	w := s.cfg.runeWidth(s.cfg.FillRune)
+	if w == 0 || width >= s.cfg.FillColumn {
+		return ""
+	}
+	return strings.Repeat(string(s.cfg.FillRune), (s.cfg.FillColumn-width)/w)
+}
+
+// guide returns the padding which fills a line with text of the given width out
+// to the limit, as set with SetColumnGuides.
+func (s *Scanner) guide(width int) string {
+	n := s.textLimit() + s.cfg.RightMargin - width
+	if n <= 0 {
+		return ""
+	}
+	return s.render(strings.Repeat(" ", n))
+}
+
+// render returns the text of a laid out line as it's to be emitted.
+func (s *Scanner) render(text string) string {
+	if s.cfg.ReplaceNBSPWithSpace {
+		text = strings.Replace(text, "\u00A0", " ", -1)
+	}
+	if s.cfg.MaskChar != 0 {
+		text = s.mask(text)
+	}
+	if s.cfg.DebugWhitespace {
+		text = showWhitespace(text)
+	}
+	return text
+}
+
+// showWhitespace renders spaces as "·" and tabs as "→", as SetDebugWhitespace
+// does.
+func showWhitespace(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ':
+			return '·'
+		case '\t':
+			return '→'
+		}
+		return r
+	}, text)
+}
+
+// mask replaces each rune of text other than whitespace with the mask rune.
+func (s *Scanner) mask(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return r
+		}
+		return s.cfg.MaskChar
+	}, text)
+}
+
+func (s *Scanner) currentPrefix() string {
+	if s.cfg.PrefixFunc != nil {
+		return s.cfg.PrefixFunc(s.lineNum)
+	}
+	return s.cfg.Prefix
+}
+
+// isBreakingSpace reports whether r is whitespace at which a line may break.
+// No-break spaces are whitespace, but are laid out as part of a word.
+func isBreakingSpace(r rune) bool {
+	switch r {
+	case '\u00A0', '\u2007', '\u202F':
+		return false
+	}
+	return unicode.IsSpace(r)
+}
+
+// isControl reports whether r is a C0 control character, DEL, or a C1 control
+// character, other than whitespace.
+func isControl(r rune) bool {
+	return (r < 0x20 || r >= 0x7F && r < 0xA0) && !unicode.IsSpace(r)
 }
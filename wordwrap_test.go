@@ -2,9 +2,13 @@ package wordwrap
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,257 +20,3732 @@ type testCase struct {
 	text   string
 	width  int
 	prefix string
+	setup  func(s *Scanner) // Applies further options, if non-nil.
 
 	expected string
 }
 
+// newTestScanner creates a Scanner configured for a test case.
+func newTestScanner(c testCase) *Scanner {
+	s := NewScanner(strings.NewReader(c.text), c.width)
+	s.SetPrefix(c.prefix)
+	if c.setup != nil {
+		c.setup(s)
+	}
+	return s
+}
+
 var allCases = map[string][]testCase{
 	"BasicText": []testCase{
 		{
 			"Long words should be broken up.",
-			"stupendous", 4, "",
+			"stupendous", 4, "", nil,
 			"stup\nendo\nus",
 		},
 		{
 			"Words should be broken on spaces.",
-			"foo bar baz", 4, "",
+			"foo bar baz", 4, "", nil,
 			"foo\nbar\nbaz",
 		},
 		{
 			"Leading/trailing space should be trimmed on wrap.",
-			"foo bar baz  ", 3, "",
+			"foo bar baz  ", 3, "", nil,
 			"foo\nbar\nbaz",
 		},
 		{
 			"Contiguous spaces should be preserved.",
-			"foo  bar  baz", 9, "",
+			"foo  bar  baz", 9, "", nil,
 			"foo  bar\nbaz",
 		},
 		{
 			"Words that would run over should be wrapped.",
-			"foo bar", 5, "",
+			"foo bar", 5, "", nil,
 			"foo\nbar",
 		},
 		{
 			"Multiple words can fit on one line.",
-			"This should split to two lines.", 20, "",
+			"This should split to two lines.", 20, "", nil,
 			"This should split to\ntwo lines.",
 		},
 		{
 			"Multiple words of exact line width should fit.",
-			"Nineteen characters", 19, "",
+			"Nineteen characters", 19, "", nil,
 			"Nineteen characters",
 		},
 		{
 			"Long runs of spaces should be trimmed.",
-			"foo            bar", 5, "",
+			"foo            bar", 5, "", nil,
 			"foo\nbar",
 		},
 	},
 	"Newlines": {
 		{
 			"Newlines should always wrap.",
-			"foo\nbar baz", 8, "",
+			"foo\nbar baz", 8, "", nil,
 			"foo\nbar baz",
 		},
 		{
 			"Newline after full line should no-op.",
-			"foo\nbar", 3, "",
+			"foo\nbar", 3, "", nil,
 			"foo\nbar",
 		},
 		{
 			"Trailing space before newline should be trimmed.",
-			"foo \nbar", 5, "",
+			"foo \nbar", 5, "", nil,
 			"foo\nbar",
 		},
 		{
 			"Explicit leading space should be preserved.",
-			"foo\n  bar", 8, "",
+			"foo\n  bar", 8, "", nil,
 			"foo\n  bar",
 		},
+		{
+			"Preserved leading space should follow the prefix.",
+			"foo\n  bar", 8, ">", nil,
+			">foo\n>  bar",
+		},
+		{
+			"Preserved leading tabs should follow the prefix.",
+			"foo\n\tbar", 8, ">", nil,
+			">foo\n>    bar",
+		},
+		{
+			"Leading space should not be repeated on wrapped lines.",
+			"foo\n  bar baz qux", 8, ">", nil,
+			">foo\n>  bar\n>baz qux",
+		},
+		{
+			"Leading space of the first line should follow the prefix.",
+			"  foo", 8, "> ", nil,
+			">   foo",
+		},
 		{
 			"Empty lines should be preserved.",
-			"foo\n\n\nbar\n", 4, "",
+			"foo\n\n\nbar\n", 4, "", nil,
 			"foo\n\n\nbar\n",
 		},
 		{
 			"Lines of all whitespace should be trimmed.",
-			"first\n  \nlast\n  ", 8, "",
+			"first\n  \nlast\n  ", 8, "", nil,
 			"first\n\nlast\n",
 		},
+		{
+			"Line separators should break like newlines.",
+			"foo\u2028bar baz", 8, "", nil,
+			"foo\nbar baz",
+		},
+		{
+			"Paragraph separators should break with an empty line.",
+			"foo\u2029bar baz", 8, "", nil,
+			"foo\n\nbar baz",
+		},
+		{
+			"A leading newline should produce an empty first line.",
+			"\nfoo", 8, "", nil,
+			"\nfoo",
+		},
+		{
+			"Consecutive leading newlines should be preserved.",
+			"\n\nfoo", 8, "", nil,
+			"\n\nfoo",
+		},
+		{
+			"Prefix should not be applied to leading empty lines.",
+			"\n\nfoo", 8, "> ", nil,
+			"\n\n> foo",
+		},
 	},
 	"Tabs": {
 		{
 			"Leading tabs should be trimmed like other whitespace.",
-			"foo\tbar", 3, "",
+			"foo\tbar", 3, "", nil,
 			"foo\nbar",
 		},
 		{
 			"Tabs after newlines should be preserved like other whitespace.",
-			"foo\n\tbar", 8, "",
+			"foo\n\tbar", 8, "", nil,
 			"foo\n    bar",
 		},
 		{
 			"Split tabs should be trimmed on both lines.",
-			"foo\tbar", 5, "",
+			"foo\tbar", 5, "", nil,
 			"foo\nbar",
 		},
 		{
 			"Tabs should maintain alignment.",
-			"1\tfoo", 8, "",
+			"1\tfoo", 8, "", nil,
 			"1   foo",
 		},
 		{
 			"Tabs should maintain alignment.",
-			"22\tfoo", 8, "",
+			"22\tfoo", 8, "", nil,
 			"22  foo",
 		},
 		{
 			"Tabs should maintain alignment.",
-			"333\tfoo", 8, "",
+			"333\tfoo", 8, "", nil,
 			"333 foo",
 		},
 		{
 			"Tabs should maintain alignment.",
-			"4444\tfoo", 12, "",
+			"4444\tfoo", 12, "", nil,
 			"4444    foo",
 		},
 	},
+	"WideTabs": {
+		{
+			"A tab past the limit should break the line.",
+			"a\tb", 8, "", func(s *Scanner) { s.SetTabWidth(20) },
+			"a\nb",
+		},
+		{
+			"A leading tab past the limit should be dropped.",
+			"\tb", 8, "", func(s *Scanner) { s.SetTabWidth(20) },
+			"b",
+		},
+		{
+			"A tab past the limit should be dropped after a newline.",
+			"a\n\tb\tc", 8, "", func(s *Scanner) { s.SetTabWidth(20) },
+			"a\nb\nc",
+		},
+		{
+			"A trailing tab past the limit should be trimmed.",
+			"a\t", 8, "", func(s *Scanner) { s.SetTabWidth(20) },
+			"a",
+		},
+	},
 	"Prefix": {
 		{
 			"Prefix should be applied to wrapped lines.",
-			"foo bar baz", 4, "--",
+			"foo bar baz", 4, "--", nil,
 			"--foo\n--bar\n--baz",
 		},
 		{
 			"Prefix should be applied to split words.",
-			"reallylongword", 4, "  ",
+			"reallylongword", 4, "  ", nil,
 			"  real\n  lylo\n  ngwo\n  rd",
 		},
 		{
 			"Prefix should be applied to explicit newlines.",
-			"foo\nbar", 8, "  ",
+			"foo\nbar", 8, "  ", nil,
 			"  foo\n  bar",
 		},
 		{
 			"Prefix should not be applied to empty lines.",
-			"foo\n\nbar\n", 8, "++",
+			"foo\n\nbar\n", 8, "++", nil,
 			"++foo\n\n++bar\n",
 		},
 		{
 			"Prefix should be applied to single lines.",
-			"foo", 4, "  ",
+			"foo", 4, "  ", nil,
 			"  foo",
 		},
+		{
+			"Prefix should not count toward the limit.",
+			"ab cd ef", 5, "--", nil,
+			"--ab cd\n--ef",
+		},
+		{
+			"Prefix should not affect tab alignment.",
+			"a\tb", 8, "--", nil,
+			"--a   b",
+		},
+		{
+			"A word as wide as the limit should fit after the prefix.",
+			"abcd", 4, "--", nil,
+			"--abcd",
+		},
+		{
+			"A word wider than the limit should wrap after the prefix.",
+			"abcde", 4, "--", nil,
+			"--abcd\n--e",
+		},
+		{
+			"A word as wide as the limit should fit on a continuation line.",
+			"ab abcd ab", 4, "--", nil,
+			"--ab\n--abcd\n--ab",
+		},
+		{
+			"A wide word as wide as the limit should fit after the prefix.",
+			"日本", 4, "--", nil,
+			"--日本",
+		},
+	},
+	"Limit": {
+		{
+			"A word of exactly the limit at EOF should fit on one line.",
+			"abcd", 4, "", nil,
+			"abcd",
+		},
+		{
+			"A word one over the limit at EOF should wrap its last character.",
+			"abcde", 4, "", nil,
+			"abcd\ne",
+		},
+		{
+			"A word of exactly twice the limit should not add an empty line.",
+			"abcdefgh", 4, "", nil,
+			"abcd\nefgh",
+		},
+		{
+			"Trailing space after a full line should not add an empty line.",
+			"abcd ", 4, "", nil,
+			"abcd",
+		},
+		{
+			"A full final word should not add an empty line.",
+			"ab abcd", 4, "", nil,
+			"ab\nabcd",
+		},
+		{
+			"A word two over the limit at EOF should wrap its last characters.",
+			"abcdef", 4, "", nil,
+			"abcd\nef",
+		},
+		{
+			"A word of exactly the limit at EOF should fit when laid out whole.",
+			"abcd", 4, "", func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			"abcd",
+		},
+		{
+			"A word one over the limit at EOF should wrap when laid out whole.",
+			"abcde", 4, "", func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			"abcd\ne",
+		},
+		{
+			"A word of exactly the limit at EOF should fit when breaking anywhere.",
+			"abcd", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abcd",
+		},
+		{
+			"A word two over the limit at EOF should wrap when breaking anywhere.",
+			"abcdef", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abcd\nef",
+		},
+		{
+			"Kept trailing space after a full line should not add an empty line.",
+			"abcd ", 4, "", func(s *Scanner) { s.SetTrimTrailingSpace(false) },
+			"abcd",
+		},
+		{
+			"A full line at the line limit should not be marked as truncated.",
+			"abcd", 4, "", func(s *Scanner) { s.SetMaxLines(1) },
+			"abcd",
+		},
 	},
 	"Degenerate": {
 		{
 			"Empty string",
-			"", 4, "++",
+			"", 4, "++", nil,
 			"",
 		},
 		{
 			"String length is exactly width.",
-			"foo", 3, "++",
+			"foo", 3, "++", nil,
 			"++foo",
 		},
 		{
 			"Input is all spaces.",
-			"   ", 4, "",
+			"   ", 4, "", nil,
 			"",
 		},
 		{
 			"Space crossing multiple line boundaries",
-			"           ", 4, "",
+			"           ", 4, "", nil,
 			"",
 		},
 		{
 			// There's no right way to handle this case, so this test is
 			// arbitrary and exists only to enforce fixed behavior.
 			"Newline followed by too much indentation",
-			"foo\n     bar", 4, "",
+			"foo\n     bar", 4, "", nil,
 			"foo\nbar",
 		},
 	},
-}
-
-func TestReadLine(t *testing.T) {
-	for name, cases := range allCases {
-		t.Run(name, func(t *testing.T) {
-			for _, c := range cases {
-				s := NewScanner(strings.NewReader(c.text), c.width)
-				s.SetPrefix(c.prefix)
-
-				expected := strings.Split(c.expected, "\n")
-
-				var lines []string
-				for {
-					line, err := s.ReadLine()
-					if err == io.EOF {
-						break
-					}
-
-					require.NoError(t, err)
-					lines = append(lines, line)
-				}
-
-				assert.Equal(t, expected, lines, c.message)
-			}
-		})
-	}
-}
-
-func TestWriteTo(t *testing.T) {
-	for name, cases := range allCases {
-		t.Run(name, func(t *testing.T) {
-			for _, c := range cases {
-				s := NewScanner(strings.NewReader(c.text), c.width)
-				s.SetPrefix(c.prefix)
-
-				buf := new(bytes.Buffer)
-				n, err := s.WriteTo(buf)
-				require.NoError(t, err)
-				assert.Equal(t, c.expected, buf.String(), c.message)
-				assert.Equal(t, len(c.expected), int(n), c.message)
-			}
-		})
-	}
-}
-
-func TestChangePrefix(t *testing.T) {
-	s := NewScanner(strings.NewReader("foo bar baz"), 4)
-
-	s.SetPrefix("--")
-	line, err := s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "--foo", line)
-
-	s.SetPrefix("+++")
-	line, err = s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "+++bar", line)
-
-	s.SetPrefix("")
-	line, err = s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "baz", line)
-}
-
-func TestChangeTabWidth(t *testing.T) {
-	s := NewScanner(strings.NewReader("first\tline\tnext\tline\tlast\tline"), 13)
-
-	s.SetTabWidth(4)
-	line, err := s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "first   line", line) // Before trim: "first   line    "
-
-	s.SetTabWidth(5)
-	line, err = s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "next line", line) // Before trim: "next line "
-
-	s.SetTabWidth(0)
-	line, err = s.ReadLine()
-	require.NoError(t, err)
-	assert.Equal(t, "lastline", line)
+	"Width": {
+		{
+			"Wide characters should count as two columns.",
+			"日本語 text", 8, "", nil,
+			"日本語\ntext",
+		},
+		{
+			"Wide words should break between characters.",
+			"日本語です", 5, "", nil,
+			"日本\n語で\nす",
+		},
+		{
+			"Fullwidth characters should count as two columns.",
+			"ＡＢ cd", 5, "", nil,
+			"ＡＢ\ncd",
+		},
+		{
+			"A wide character wider than the limit should overflow it.",
+			"日本", 1, "", nil,
+			"日\n本",
+		},
+		{
+			"Wide whitespace should count as two columns.",
+			"ab　cd", 5, "", nil,
+			"ab\ncd",
+		},
+		{
+			"Ambiguous characters should count as one column by default.",
+			"αβγ δεζ", 8, "", nil,
+			"αβγ δεζ",
+		},
+		{
+			"Ambiguous characters should count as one column if set.",
+			"αβγ δεζ", 8, "", func(s *Scanner) { s.SetAmbiguousWidth(1) },
+			"αβγ δεζ",
+		},
+		{
+			"Ambiguous characters should count as two columns if set.",
+			"αβγ δεζ", 8, "", func(s *Scanner) { s.SetAmbiguousWidth(2) },
+			"αβγ\nδεζ",
+		},
+		{
+			"Ambiguous punctuation should count as two columns if set.",
+			"5±1 mm", 6, "", func(s *Scanner) { s.SetAmbiguousWidth(2) },
+			"5±1\nmm",
+		},
+	},
+	"TrailingSpace": {
+		{
+			"Trailing space should be trimmed by default.",
+			"foo   ", 9, "", nil,
+			"foo",
+		},
+		{
+			"Trailing space should be kept at EOF.",
+			"foo   ", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"foo   ",
+		},
+		{
+			"Trailing space should be kept before a newline.",
+			"foo  \nbar", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"foo  \nbar",
+		},
+		{
+			"Trailing tabs should be expanded.",
+			"foo\t", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"foo     ",
+		},
+		{
+			"Trailing space should be truncated at the limit.",
+			"foo bar      ", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"foo bar  ",
+		},
+		{
+			"Space at a wrap should still be removed.",
+			"foo bar   baz", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"foo bar\nbaz",
+		},
+		{
+			"Whitespace-only lines should be kept.",
+			"  \nfoo", 9, "", func(s *Scanner) { s.SetTabWidth(8); s.SetTrimTrailingSpace(false) },
+			"  \nfoo",
+		},
+	},
+	"Reflow": {
+		{
+			"Lines within a paragraph should be joined.",
+			"one two\nthree\nfour five", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one two three\nfour five",
+		},
+		{
+			"Blank lines should separate paragraphs.",
+			"one\ntwo\n\nthree\nfour", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one two\n\nthree four",
+		},
+		{
+			"Consecutive blank lines should be kept.",
+			"one\n\n\ntwo", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one\n\n\ntwo",
+		},
+		{
+			"Whitespace-only lines should separate paragraphs.",
+			"one\n  \ntwo", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one\n\ntwo",
+		},
+		{
+			"Leading blank lines should be kept.",
+			"\none\ntwo", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"\none two",
+		},
+		{
+			"Trailing newlines should be kept.",
+			"one\ntwo\n", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one two\n",
+		},
+		{
+			"First-line indentation should be kept.",
+			"  one\n  two", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"  one two",
+		},
+		{
+			"Line separators should still break lines.",
+			"one\u2028two\nthree", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"one\ntwo three",
+		},
+		{
+			"Without dehyphenation, hyphens should be left alone.",
+			"frag-\nment", 14, "", func(s *Scanner) { s.SetReflow(true) },
+			"frag- ment",
+		},
+	},
+	"Dehyphenate": {
+		{
+			"Hyphenated words should be rejoined.",
+			"a frag-\nment of text", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"a fragment\nof text",
+		},
+		{
+			"Indented continuations should be rejoined.",
+			"frag-\n   ment", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"fragment",
+		},
+		{
+			"Uppercase continuations should keep the hyphen.",
+			"Jean-\nPaul Sartre", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"Jean-Paul\nSartre",
+		},
+		{
+			"Hyphens within a line should be left alone.",
+			"well-known\nfact", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"well-known\nfact",
+		},
+		{
+			"Known compounds broken after the hyphen should keep it.",
+			"a well-\nknown fact", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"a well-known\nfact",
+		},
+		{
+			"Known compounds should be recognized after punctuation.",
+			"(Self-\nmade)", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"(Self-made)",
+		},
+		{
+			"Words already holding a hyphen should keep it.",
+			"state-of-the-\nart", 20, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"state-of-the-art",
+		},
+		{
+			"Dashes should not join words.",
+			"this -\nthat", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"this - that",
+		},
+		{
+			"Paragraph breaks should not be joined.",
+			"frag-\n\nment", 12, "", func(s *Scanner) { s.SetReflow(true); s.SetDehyphenate(true) },
+			"frag-\n\nment",
+		},
+	},
+	"TabStops": {
+		{
+			"Tabs should advance to each stop.",
+			"a\tb\tc", 20, "", func(s *Scanner) { s.SetTabStops([]int{4, 10}) },
+			"a   b     c",
+		},
+		{
+			"Tabs past the last stop should use the tab width.",
+			"a\tb\tc\td\te", 20, "", func(s *Scanner) { s.SetTabStops([]int{4, 10}) },
+			"a   b     c d   e",
+		},
+		{
+			"A tab at a stop should advance to the next.",
+			"abcd\te", 20, "", func(s *Scanner) { s.SetTabStops([]int{4, 10}) },
+			"abcd      e",
+		},
+		{
+			"Tabs should wrap as other whitespace.",
+			"a\tb\tc\td\te\tfffff", 20, "", func(s *Scanner) { s.SetTabStops([]int{4, 10}) },
+			"a   b     c d   e\nfffff",
+		},
+	},
+	"Mask": {
+		{
+			"Masked text should wrap as the original.",
+			"secret word", 8, "", func(s *Scanner) { s.SetMaskChar('•') },
+			"••••••\n••••",
+		},
+		{
+			"Whitespace should pass through.",
+			"ab cd\n\tef", 10, "", func(s *Scanner) { s.SetMaskChar('•') },
+			"•• ••\n    ••",
+		},
+		{
+			"Long words should be split as the original.",
+			"日本語です", 6, "", func(s *Scanner) { s.SetMaskChar('•') },
+			"•••\n••",
+		},
+		{
+			"The prefix should not be masked.",
+			"ab cd", 10, "> ", func(s *Scanner) { s.SetMaskChar('•') },
+			"> •• ••",
+		},
+	},
+	"AlignToTab": {
+		{
+			"Continuation lines should align with the text after the tab.",
+			"  -v\tverbose output that is long enough to wrap", 24, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"  -v    verbose output\n        that is long\n        enough to wrap",
+		},
+		{
+			"Alignment should apply when laying out whole lines.",
+			"  -v\tverbose output that is long enough to wrap", 24, "", func(s *Scanner) {
+				s.SetAlignContinuationToTab(true)
+				s.SetBreakPreference(BreakBeforeShort)
+			},
+			"  -v    verbose output\n        that is long\n        enough to wrap",
+		},
+		{
+			"Lines without a tab should not be indented.",
+			"no tab here at all", 8, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"no tab\nhere at\nall",
+		},
+		{
+			"Only the first tab should set the indent.",
+			"a\tb\tc d e", 10, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"a   b   c\n    d e",
+		},
+		{
+			"A tab ending past the limit should not set the indent.",
+			"abcdefgh\tij kl", 10, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"abcdefgh\nij kl",
+		},
+		{
+			"Long words should be split to fit after the indent.",
+			"-v\tab cdefghijkl", 10, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"-v  ab\n    cdefgh\n    ijkl",
+		},
+		{
+			"A tab whose word doesn't fit on the first line should not set the indent.",
+			"a bb\tccccc d eeee", 12, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"a bb\nccccc d eeee",
+		},
+		{
+			"A tab whose word doesn't fit should not set the indent when laying out whole lines.",
+			"a bb\tccccc d eeee", 12, "", func(s *Scanner) {
+				s.SetAlignContinuationToTab(true)
+				s.SetBreakPreference(MinRagged)
+			},
+			"a bb\nccccc d eeee",
+		},
+		{
+			"The indent should follow the prefix.",
+			"-v\tone two three", 10, "> ", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"> -v  one\n>     two\n>     three",
+		},
+		{
+			"A newline should end the indent.",
+			"-v\tone two\nthree four five", 10, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"-v  one\n    two\nthree four\nfive",
+		},
+	},
+	"CombiningMarks": {
+		{
+			"Combining marks should occupy no columns.",
+			"cafe\u0301 ok", 7, "", nil,
+			"cafe\u0301 ok",
+		},
+		{
+			"Combining marks should not be orphaned when breaking words.",
+			"cafe\u0301s", 4, "", nil,
+			"cafe\u0301\ns",
+		},
+		{
+			"Several combining marks should stay with their base.",
+			"abo\u0323\u0302ut", 3, "", nil,
+			"abo\u0323\u0302\nut",
+		},
+		{
+			"Combining marks should stay with a base wider than the limit.",
+			"日\u0301本", 1, "", nil,
+			"日\u0301\n本",
+		},
+		{
+			"Spacing marks should overflow rather than be orphaned.",
+			"\u0915\u093F\u0915", 1, "", nil,
+			"\u0915\u093F\n\u0915",
+		},
+		{
+			"Ambiguous combining marks should occupy no columns if set.",
+			"e\u0301e\u0301 ab", 5, "", func(s *Scanner) { s.SetAmbiguousWidth(2) },
+			"e\u0301e\u0301 ab",
+		},
+	},
+	"TabWordBreak": {
+		{
+			"A tab between words should fit as usual by default.",
+			"foo\tbar", 7, "", nil,
+			"foo bar",
+		},
+		{
+			"A tab between words should fit as usual when joining.",
+			"foo\tbar", 7, "", func(s *Scanner) { s.SetTabIsWordBreak(false) },
+			"foo bar",
+		},
+		{
+			"A tab between words should allow a break by default.",
+			"x foo\tbar", 8, "", func(s *Scanner) { s.SetTabIsWordBreak(true) },
+			"x foo\nbar",
+		},
+		{
+			"Words joined by a tab should move to the next line together.",
+			"x foo\tbar", 8, "", func(s *Scanner) { s.SetTabIsWordBreak(false) },
+			"x\nfoo bar",
+		},
+		{
+			"Several tabs should join words.",
+			"x ab\t\tcd", 10, "", func(s *Scanner) { s.SetTabIsWordBreak(false) },
+			"x\nab      cd",
+		},
+		{
+			"A run of joined words should break if it can't fit on a line.",
+			"foo\tbarbaz", 6, "", func(s *Scanner) { s.SetTabIsWordBreak(false) },
+			"foo\nbarbaz",
+		},
+		{
+			"A tab next to a space should not join words.",
+			"x foo\t bar", 8, "", func(s *Scanner) { s.SetTabIsWordBreak(false) },
+			"x foo\nbar",
+		},
+		{
+			"Joined words should move together when laying out whole lines.",
+			"aa bb foo\tbar cc", 9, "", func(s *Scanner) {
+				s.SetTabIsWordBreak(false)
+				s.SetBreakPreference(MinRagged)
+			},
+			"aa bb\nfoo bar\ncc",
+		},
+		{
+			"A short word joined by a tab should not be moved alone.",
+			"aaa foo\tb cc", 9, "", func(s *Scanner) {
+				s.SetTabIsWordBreak(false)
+				s.SetBreakPreference(BreakBeforeShort)
+			},
+			"aaa foo b\ncc",
+		},
+		{
+			"A trailing tab should not join lines when reflowing.",
+			"foo\t\nbar", 20, "", func(s *Scanner) {
+				s.SetTabIsWordBreak(false)
+				s.SetReflow(true)
+			},
+			"foo bar",
+		},
+	},
+	"Directives": {
+		{
+			"A directive should center the following paragraph.",
+			".center\nab cd\n\nef", 9, "", func(s *Scanner) { s.SetDirectives(true) },
+			"  ab cd\n\nef",
+		},
+		{
+			"Every line of the paragraph should be centered.",
+			".center\none two three\nfour", 9, "", func(s *Scanner) { s.SetDirectives(true) },
+			" one two\n  three\n  four",
+		},
+		{
+			"A directive should right-align the following paragraph.",
+			".right\nab\ncd", 6, "", func(s *Scanner) { s.SetDirectives(true) },
+			"    ab\n    cd",
+		},
+		{
+			"A later directive should override an earlier one.",
+			".center\n.left\nab", 6, "", func(s *Scanner) { s.SetDirectives(true) },
+			"ab",
+		},
+		{
+			"A directive at EOF should be removed.",
+			"ab\n\n.right", 6, "", func(s *Scanner) { s.SetDirectives(true) },
+			"ab\n\n",
+		},
+		{
+			"Unknown directives should pass through as text.",
+			".centre\nab", 10, "", func(s *Scanner) { s.SetDirectives(true) },
+			".centre\nab",
+		},
+		{
+			"Directives with whitespace should pass through as text.",
+			" .center\n.center \nab", 10, "", func(s *Scanner) { s.SetDirectives(true) },
+			" .center\n.center\nab",
+		},
+		{
+			"Directives within a paragraph should pass through as text.",
+			"ab\n.center\ncd", 10, "", func(s *Scanner) { s.SetDirectives(true) },
+			"ab\n.center\ncd",
+		},
+		{
+			"Directives should be text unless enabled.",
+			".center\nab", 10, "", nil,
+			".center\nab",
+		},
+		{
+			"Directives should apply to reflowed paragraphs.",
+			".center\none\ntwo\n\nthree", 9, "", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetReflow(true)
+			},
+			" one two\n\nthree",
+		},
+		{
+			"Aligned lines should follow the prefix.",
+			".right\nab", 5, "> ", func(s *Scanner) { s.SetDirectives(true) },
+			">    ab",
+		},
+	},
+	"TrailingNewlines": {
+		{
+			"Input without a trailing newline should end with its text.",
+			"foo", 8, "", nil,
+			"foo",
+		},
+		{
+			"One trailing newline should end with one empty line.",
+			"foo\n", 8, "", nil,
+			"foo\n",
+		},
+		{
+			"Two trailing newlines should end with two empty lines.",
+			"foo\n\n", 8, "", nil,
+			"foo\n\n",
+		},
+		{
+			"Three trailing newlines should end with three empty lines.",
+			"foo\n\n\n", 8, "", nil,
+			"foo\n\n\n",
+		},
+		{
+			"Newlines alone should each produce an empty line.",
+			"\n\n", 8, "", nil,
+			"\n\n",
+		},
+		{
+			"Trailing whitespace should not change the count.",
+			"foo \n \n", 8, "", nil,
+			"foo\n\n",
+		},
+		{
+			"Normalized newlines should each count once.",
+			"foo\r\n\r\n", 8, "", func(s *Scanner) { s.SetNormalizeNewlines(true, false) },
+			"foo\n\n",
+		},
+		{
+			"Reflowing should not change the count.",
+			"foo\nbar\n\n\n", 8, "", func(s *Scanner) { s.SetReflow(true) },
+			"foo bar\n\n\n",
+		},
+		{
+			"The line for the final newline should not be prefixed.",
+			"foo\n\n", 8, "> ", func(s *Scanner) { s.SetPrefixOnBlankLines(true) },
+			"> foo\n> \n",
+		},
+	},
+	"MaxLines": {
+		{
+			"Lines past the maximum should be dropped.",
+			"one two three four five six seven", 10, "", func(s *Scanner) { s.SetMaxLines(2) },
+			"one two\nthree four",
+		},
+		{
+			"The ellipsis should be cut to fit the limit.",
+			"one two three four five six seven", 10, "", func(s *Scanner) {
+				s.SetMaxLines(2)
+				s.SetEllipsis("…")
+			},
+			"one two\nthree fou…",
+		},
+		{
+			"The ellipsis should follow a line with room for it.",
+			"one two three four five six seven", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetEllipsis("…")
+			},
+			"one two…",
+		},
+		{
+			"Nothing should be marked if no lines are dropped.",
+			"one two three four five six seven", 10, "", func(s *Scanner) {
+				s.SetMaxLines(4)
+				s.SetEllipsis("…")
+			},
+			"one two\nthree four\nfive six\nseven",
+		},
+		{
+			"A trailing newline should not count as a dropped line.",
+			"one two\n", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetEllipsis("…")
+			},
+			"one two",
+		},
+		{
+			"An empty line should be marked if lines are dropped.",
+			"ab\n\ncd", 10, "", func(s *Scanner) {
+				s.SetMaxLines(2)
+				s.SetEllipsis("…")
+			},
+			"ab\n…",
+		},
+		{
+			"The suffix should report the number of lines dropped.",
+			"one two three four five six seven", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetTruncationSuffix(func(n int) string { return fmt.Sprintf("… +%d", n) })
+			},
+			"one tw… +3",
+		},
+		{
+			"The suffix should not count a trailing newline.",
+			"one two three four five six seven\n", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetTruncationSuffix(func(n int) string { return fmt.Sprintf("… +%d", n) })
+			},
+			"one tw… +3",
+		},
+		{
+			"The suffix should follow the prefix and text.",
+			"one two three four five six seven", 10, "> ", func(s *Scanner) {
+				s.SetMaxLines(3)
+				s.SetEllipsis("...")
+			},
+			"> one two\n> three four\n> five si...",
+		},
+		{
+			"The suffix should not be masked.",
+			"one two three four five six seven", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetEllipsis("…")
+				s.SetMaskChar('*')
+			},
+			"*** ***…",
+		},
+		{
+			"A line already at the limit should be shortened for the ellipsis.",
+			"abcdefghij klm", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetEllipsis("...")
+			},
+			"abcdefg...",
+		},
+		{
+			"A line already at the limit should be shortened for the suffix.",
+			"abcdefghij klm", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetTruncationSuffix(func(n int) string { return fmt.Sprintf("… +%d", n) })
+			},
+			"abcdef… +1",
+		},
+		{
+			"A suffix wider than the limit should be cut to fit.",
+			"abcdefghij klm", 10, "", func(s *Scanner) {
+				s.SetMaxLines(1)
+				s.SetTruncationSuffix(func(n int) string { return fmt.Sprintf("… (+%d more lines)", n) })
+			},
+			"… (+1 more",
+		},
+	},
+	"UnicodeLineBreaks": {
+		{
+			"Lines should break between ideographs.",
+			"日本語のテキスト", 6, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"日本語\nのテキ\nスト",
+		},
+		{
+			"Lines should break between East Asian and other text.",
+			"abc日本", 4, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"abc\n日本",
+		},
+		{
+			"Lines should not break before closing punctuation.",
+			"あいう。え", 6, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"あい\nう。え",
+		},
+		{
+			"Lines should not break after opening punctuation.",
+			"あい「う", 6, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"あい\n「う",
+		},
+		{
+			"Closing punctuation should be orphaned without line breaking rules.",
+			"あいう。え", 6, "", nil,
+			"あいう\n。え",
+		},
+		{
+			"Lines should break before small kana by default.",
+			"あいうぇお", 6, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"あいう\nぇお",
+		},
+		{
+			"A Japanese locale should not break before small kana.",
+			"あいうぇお", 6, "", func(s *Scanner) {
+				s.SetUnicodeLineBreaks(true)
+				s.SetLocale("ja-JP")
+			},
+			"あい\nうぇお",
+		},
+		{
+			"A Japanese locale should not break before the prolonged sound mark.",
+			"アイスクリーム", 10, "", func(s *Scanner) {
+				s.SetUnicodeLineBreaks(true)
+				s.SetLocale("ja-JP")
+			},
+			"アイスク\nリーム",
+		},
+		{
+			"The prolonged sound mark may begin a line by default.",
+			"アイスクリーム", 10, "", func(s *Scanner) { s.SetUnicodeLineBreaks(true) },
+			"アイスクリ\nーム",
+		},
+		{
+			"Other locales should use the default rules.",
+			"あいうぇお", 6, "", func(s *Scanner) {
+				s.SetUnicodeLineBreaks(true)
+				s.SetLocale("zh-Hans")
+			},
+			"あいう\nぇお",
+		},
+		{
+			"The locale should have no effect without line breaking rules.",
+			"あいうぇお", 6, "", func(s *Scanner) { s.SetLocale("ja") },
+			"あいう\nぇお",
+		},
+	},
+	"DebugWhitespace": {
+		{
+			"Spaces should be visible and trailing whitespace marked as trimmed.",
+			"foo  bar\t", 10, "", func(s *Scanner) { s.SetDebugWhitespace(true) },
+			"foo··bar×",
+		},
+		{
+			"Whitespace trimmed at a wrap should be marked.",
+			"foo  bar\t", 5, "", func(s *Scanner) { s.SetDebugWhitespace(true) },
+			"foo××\nbar×",
+		},
+		{
+			"Tabs should be marked where they begin.",
+			"a\tb", 10, "", func(s *Scanner) { s.SetDebugWhitespace(true) },
+			"a→··b",
+		},
+		{
+			"Blank lines should show trimmed whitespace.",
+			"a\n  \nb", 10, "", func(s *Scanner) { s.SetDebugWhitespace(true) },
+			"a\n××\nb",
+		},
+		{
+			"Whitespace kept past the limit should be marked where it was cut.",
+			"foo   \nbar", 5, "", func(s *Scanner) {
+				s.SetDebugWhitespace(true)
+				s.SetTrimTrailingSpace(false)
+			},
+			"foo··×\nbar",
+		},
+		{
+			"Whitespace should be visible in buffered paragraphs.",
+			"aa bb  cc dd", 6, "", func(s *Scanner) {
+				s.SetDebugWhitespace(true)
+				s.SetBreakPreference(MinRagged)
+			},
+			"aa·bb××\ncc·dd",
+		},
+		{
+			"Prefixes should not be rendered.",
+			"a b", 10, "> ", func(s *Scanner) { s.SetDebugWhitespace(true) },
+			"> a·b",
+		},
+	},
+	"MinParagraphGap": {
+		{
+			"A single blank line should become two.",
+			"aa\n\nbb\n\ncc", 10, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"aa\n\n\nbb\n\n\ncc",
+		},
+		{
+			"Longer gaps should be kept.",
+			"aa\n\n\n\nbb", 10, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"aa\n\n\n\nbb",
+		},
+		{
+			"Lines without a gap aren't separate paragraphs.",
+			"aa\nbb", 10, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"aa\nbb",
+		},
+		{
+			"Blank lines at the start and end of input should be left alone.",
+			"\naa\n\n", 10, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"\naa\n\n",
+		},
+		{
+			"Whitespace-only lines should count as blank.",
+			"aa\n  \nbb", 10, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"aa\n\n\nbb",
+		},
+		{
+			"Wrapped paragraphs should be separated.",
+			"aa bb\n\ncc dd", 3, "", func(s *Scanner) { s.SetMinParagraphGap(2) },
+			"aa\nbb\n\n\ncc\ndd",
+		},
+		{
+			"Reflowed paragraphs should be separated.",
+			"aa\nbb\n\ncc", 10, "", func(s *Scanner) {
+				s.SetMinParagraphGap(2)
+				s.SetReflow(true)
+			},
+			"aa bb\n\n\ncc",
+		},
+		{
+			"Added lines should be prefixed like other blank lines.",
+			"aa\n\nbb", 10, "> ", func(s *Scanner) {
+				s.SetMinParagraphGap(2)
+				s.SetPrefixOnBlankLines(true)
+			},
+			"> aa\n> \n> \n> bb",
+		},
+	},
+	"PrefixWhitespace": {
+		{
+			"Tabs in the prefix should be expanded from the start of the line.",
+			"aa bb", 10, ">\t", nil,
+			">   aa bb",
+		},
+		{
+			"Tabs in the prefix should follow the tab width.",
+			"aa bb", 10, "\t", func(s *Scanner) { s.SetTabWidth(2) },
+			"  aa bb",
+		},
+		{
+			"Text tabs should not depend on the prefix.",
+			"a\tb", 10, ">\t", nil,
+			">   a   b",
+		},
+		{
+			"Newlines in the prefix should not split lines.",
+			"aa bb cc", 5, "1\n2 ", nil,
+			"1 2 aa bb\n1 2 cc",
+		},
+		{
+			"Other line breaks in the prefix should not split lines.",
+			"aa", 5, "1\r2\u2028", nil,
+			"1 2 aa",
+		},
+		{
+			"Newlines from a prefix function should not split lines.",
+			"aa bb cc", 5, "", func(s *Scanner) { s.SetPrefixFunc(func(int) string { return ">\n" }) },
+			"> aa bb\n> cc",
+		},
+	},
+	"PreferSentenceBreaks": {
+		{
+			"Lines should break after a sentence.",
+			"One two. Three four five.", 16, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"One two.\nThree four five.",
+		},
+		{
+			"Lines should fill without the preference.",
+			"One two. Three four five.", 16, "", nil,
+			"One two. Three\nfour five.",
+		},
+		{
+			"Lines should break after the last sentence which fits.",
+			"A. B. C. Dee eee.", 10, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"A. B. C.\nDee eee.",
+		},
+		{
+			"Closing quotes may follow the punctuation.",
+			"He said \"go!\" and left.", 16, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"He said \"go!\"\nand left.",
+		},
+		{
+			"Lines without a sentence end should break as usual.",
+			"one two three four", 10, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"one two\nthree four",
+		},
+		{
+			"Lines which fit should not break.",
+			"One. Two.", 16, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"One. Two.",
+		},
+		{
+			"A sentence ending the first word should still break.",
+			"Yes? No way.", 10, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"Yes?\nNo way.",
+		},
+		{
+			"Each line of input should be laid out separately.",
+			"A. b c\nd. e f", 6, "", func(s *Scanner) { s.SetPreferSentenceBreaks(true) },
+			"A. b c\nd. e f",
+		},
+	},
+	"PrefixPlacement": {
+		{
+			"The prefix should stay at the margin of right-aligned lines.",
+			".right\nab cd ef", 6, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetPrefixPlacement(PrefixMargin)
+			},
+			">  ab cd\n>     ef",
+		},
+		{
+			"The prefix should hug right-aligned text.",
+			".right\nab cd ef", 6, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetPrefixPlacement(PrefixHug)
+			},
+			" > ab cd\n    > ef",
+		},
+		{
+			"The prefix should hug centered text.",
+			".center\nab", 6, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetPrefixPlacement(PrefixHug)
+			},
+			"  > ab",
+		},
+		{
+			"Left-aligned lines should be unaffected.",
+			"ab cd ef", 6, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetPrefixPlacement(PrefixHug)
+			},
+			"> ab cd\n> ef",
+		},
+		{
+			"The margin should be the default.",
+			".right\nab", 6, "> ", func(s *Scanner) { s.SetDirectives(true) },
+			">     ab",
+		},
+	},
+	"BreakAnywhere": {
+		{
+			"Words should be chopped to fill each line.",
+			"hello world", 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"hel\nlo\nwor\nld",
+		},
+		{
+			"Words should continue after a space on the same line.",
+			"hello world", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"hell\no wo\nrld",
+		},
+		{
+			"Words which would fit on the next line should still be broken.",
+			"ab cdef", 5, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"ab cd\nef",
+		},
+		{
+			"Words should move to the next line without the option.",
+			"ab cdef", 5, "", nil,
+			"ab\ncdef",
+		},
+		{
+			"Wide characters should not be split.",
+			"a 日本語", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"a 日\n本語",
+		},
+		{
+			"A wide character which doesn't fit should begin the next line.",
+			"abc 日本", 5, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abc\n日本",
+		},
+		{
+			"Combining marks should stay with their character.",
+			"abe\u0301cd", 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abe\u0301\ncd",
+		},
+		{
+			"Newlines should still end lines.",
+			"abcd\nef", 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abc\nd\nef",
+		},
+		{
+			"Prefixes should not count toward the limit.",
+			"abcdef", 4, "> ", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"> abcd\n> ef",
+		},
+	},
+	"RightMargin": {
+		{
+			"Text should break at the limit less the margin.",
+			"aaaa bbb ccc", 10, "", func(s *Scanner) { s.SetRightMargin(2) },
+			"aaaa bbb\nccc",
+		},
+		{
+			"Text should break at the limit without a margin.",
+			"aaaa bbb ccc", 10, "", nil,
+			"aaaa bbb\nccc",
+		},
+		{
+			"A line as wide as the limit should break with a margin.",
+			"aaaa bbbbb", 10, "", func(s *Scanner) { s.SetRightMargin(2) },
+			"aaaa\nbbbbb",
+		},
+		{
+			"Long words should be split at the margin.",
+			"abcdefghij", 10, "", func(s *Scanner) { s.SetRightMargin(2) },
+			"abcdefgh\nij",
+		},
+		{
+			"The prefix should not take from the margin.",
+			"aaaa bbb ccc", 10, "> ", func(s *Scanner) { s.SetRightMargin(2) },
+			"> aaaa bbb\n> ccc",
+		},
+		{
+			"Right-aligned lines should stop short of the margin.",
+			".right\nab", 10, "", func(s *Scanner) {
+				s.SetRightMargin(2)
+				s.SetDirectives(true)
+			},
+			"      ab",
+		},
+		{
+			"Right-aligned lines may extend into the margin.",
+			".right\nab cd efg", 10, "", func(s *Scanner) {
+				s.SetRightMargin(2)
+				s.SetAlignIntoRightMargin(true)
+				s.SetDirectives(true)
+			},
+			"     ab cd\n       efg",
+		},
+	},
+	"KeepNumbers": {
+		{
+			"An address should be kept whole.",
+			"at 192.168.000.001 now", 6, "", func(s *Scanner) { s.SetKeepNumbers(true) },
+			"at\n192.168.000.001\nnow",
+		},
+		{
+			"An address should be split without the option.",
+			"at 192.168.000.001 now", 6, "", nil,
+			"at\n192.16\n8.000.\n001\nnow",
+		},
+		{
+			"Grouped digits should be kept whole.",
+			"1,234,567 items", 5, "", func(s *Scanner) { s.SetKeepNumbers(true) },
+			"1,234,567\nitems",
+		},
+		{
+			"Trailing punctuation and signs should be allowed.",
+			"was -1,234.5.", 4, "", func(s *Scanner) { s.SetKeepNumbers(true) },
+			"was\n-1,234.5.",
+		},
+		{
+			"Other words should still be split.",
+			"v1.2.3a", 4, "", func(s *Scanner) { s.SetKeepNumbers(true) },
+			"v1.2\n.3a",
+		},
+		{
+			"Numbers should be kept whole while breaking anywhere.",
+			"ab 1.234", 4, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetBreakAnywhere(true)
+			},
+			"ab\n1.234",
+		},
+		{
+			"Numbers should be kept whole in buffered paragraphs.",
+			"a 12.345 b", 4, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetBreakPreference(MinRagged)
+			},
+			"a\n12.345\nb",
+		},
+	},
+	"Footnotes": {
+		{
+			"A marker should be replaced by a footnote block.",
+			"see * here", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[note one]"), '*') },
+			"see [note one] here",
+		},
+		{
+			"Footnote text should wrap with the main text.",
+			"see * here", 10, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[a long note]"), '*') },
+			"see [a\nlong note]\nhere",
+		},
+		{
+			"Each marker should take the next block.",
+			"a * b * c", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[1]\n\n[2]"), '*') },
+			"a [1] b [2] c",
+		},
+		{
+			"Lines of a block should be joined.",
+			"a *", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[one\ntwo]\n\n"), '*') },
+			"a [one two]",
+		},
+		{
+			"Footnotes should not nest.",
+			"a *", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[b *]\n\n[c]"), '*') },
+			"a [b *]",
+		},
+		{
+			"Markers should be kept once footnotes run out.",
+			"a * b *", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("[1]"), '*') },
+			"a [1] b *",
+		},
+		{
+			"Blank lines before a block should be skipped.",
+			"a*", 20, "", func(s *Scanner) { s.SetFootnoteReader(strings.NewReader("\r\n\r\n(1)\r\n\r\n(2)"), '*') },
+			"a(1)",
+		},
+		{
+			"Markers should be text without a footnote reader.",
+			"a * b", 20, "", nil,
+			"a * b",
+		},
+	},
+	"TruncateOverflow": {
+		{
+			"An overflowing token should be truncated with an ellipsis.",
+			"a 1234567890123456789012345678901234567890 b", 20, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetTruncateOverflow(10, "…")
+			},
+			"a\n123456789…\nb",
+		},
+		{
+			"An overflowing token should be cut without an ellipsis.",
+			"1234567890123456789012345678901234567890", 20, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetTruncateOverflow(10, "")
+			},
+			"1234567890",
+		},
+		{
+			"The prefix should count toward the cap.",
+			"1234567890123456789012345678901234567890", 20, "> ", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetTruncateOverflow(10, "...")
+			},
+			"> 12345...",
+		},
+		{
+			"Lines within the cap should be unchanged.",
+			"aaa bbb ccc", 8, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetTruncateOverflow(10, "…")
+			},
+			"aaa bbb\nccc",
+		},
+		{
+			"A wide ellipsis should be cut to the cap.",
+			"1234567890123456789012345678901234567890", 20, "", func(s *Scanner) {
+				s.SetKeepNumbers(true)
+				s.SetTruncateOverflow(2, "…!!")
+			},
+			"…!",
+		},
+		{
+			"Wide characters should not be split.",
+			"日本語", 1, "", func(s *Scanner) { s.SetTruncateOverflow(3, "") },
+			"日\n本\n語",
+		},
+		{
+			"A prefix on a blank line should be capped.",
+			"a\n\nb", 10, "> > > ", func(s *Scanner) {
+				s.SetPrefixOnBlankLines(true)
+				s.SetTruncateOverflow(4, "")
+			},
+			"> > \n> > \n> > ",
+		},
+	},
+	"ColumnGuides": {
+		{
+			"Each line should be padded to the limit.",
+			"aa bb cc", 6, "", func(s *Scanner) { s.SetColumnGuides(true) },
+			"aa bb \ncc    ",
+		},
+		{
+			"Wide characters should count double.",
+			"日本 a", 8, "", func(s *Scanner) { s.SetColumnGuides(true) },
+			"日本 a  ",
+		},
+		{
+			"Prefixes should not count toward the padding.",
+			"aa bb", 4, "> ", func(s *Scanner) { s.SetColumnGuides(true) },
+			"> aa  \n> bb  ",
+		},
+		{
+			"Blank lines should not be padded by default.",
+			"a\n\nb\n", 3, "", func(s *Scanner) { s.SetColumnGuides(true) },
+			"a  \n\nb  \n",
+		},
+		{
+			"Blank lines may be padded.",
+			"a\n\nb\n", 3, "", func(s *Scanner) {
+				s.SetColumnGuides(true)
+				s.SetPadBlankLines(true)
+			},
+			"a  \n   \nb  \n",
+		},
+		{
+			"Aligned lines should be padded after the text.",
+			".center\nab", 6, "", func(s *Scanner) {
+				s.SetColumnGuides(true)
+				s.SetDirectives(true)
+			},
+			"  ab  ",
+		},
+		{
+			"Lines with the prefix hugging text should be padded.",
+			".right\nab", 6, ">", func(s *Scanner) {
+				s.SetColumnGuides(true)
+				s.SetDirectives(true)
+				s.SetPrefixPlacement(PrefixHug)
+			},
+			"    >ab",
+		},
+		{
+			"Padding should fill the right margin.",
+			"aa bb", 6, "", func(s *Scanner) {
+				s.SetColumnGuides(true)
+				s.SetRightMargin(2)
+			},
+			"aa    \nbb    ",
+		},
+		{
+			"Overflowing lines should not be padded.",
+			"日本", 1, "", func(s *Scanner) { s.SetColumnGuides(true) },
+			"日\n本",
+		},
+	},
+	"BreakOnUnderscore": {
+		{
+			"Identifiers should break after underscores.",
+			"some_long_identifier_name", 8, "", func(s *Scanner) { s.SetBreakOnUnderscore(true) },
+			"some_\nlong_\nidentifi\ner_name",
+		},
+		{
+			"Identifiers should be split without the option.",
+			"some_long_identifier_name", 8, "", nil,
+			"some_lon\ng_identi\nfier_nam\ne",
+		},
+		{
+			"Parts should be packed onto a line.",
+			"a_b_c_d e", 4, "", func(s *Scanner) { s.SetBreakOnUnderscore(true) },
+			"a_b_\nc_d\ne",
+		},
+		{
+			"Runs of underscores should not be broken.",
+			"ab__cd", 4, "", func(s *Scanner) { s.SetBreakOnUnderscore(true) },
+			"ab__\ncd",
+		},
+		{
+			"Leading underscores should not be broken.",
+			"__init__ x", 4, "", func(s *Scanner) { s.SetBreakOnUnderscore(true) },
+			"__in\nit__\nx",
+		},
+		{
+			"Underscores should combine with East Asian breaks.",
+			"日本_abc", 4, "", func(s *Scanner) {
+				s.SetBreakOnUnderscore(true)
+				s.SetUnicodeLineBreaks(true)
+			},
+			"日本\n_abc",
+		},
+	},
+	"LastLine": {
+		{
+			"The last line of text should be marked.",
+			"aa bb cc dd", 5, "", func(s *Scanner) { s.SetLastLineSuffix("$") },
+			"aa bb\ncc dd$",
+		},
+		{
+			"A trailing newline should not be marked.",
+			"aa bb cc\n", 5, "", func(s *Scanner) { s.SetLastLineSuffix("$") },
+			"aa bb\ncc$\n",
+		},
+		{
+			"Only the last paragraph should be marked.",
+			"aa\n\nbb cc\n\n", 5, "", func(s *Scanner) { s.SetLastLineSuffix("$") },
+			"aa\n\nbb cc$\n\n",
+		},
+		{
+			"Each paragraph should be marked by paragraph.",
+			"aa bb cc\n\ndd\n", 5, "", func(s *Scanner) {
+				s.SetLastLineMode(LastLineOfParagraph)
+				s.SetLastLineSuffix("$")
+			},
+			"aa bb\ncc$\n\ndd$\n",
+		},
+		{
+			"Lines within a paragraph should not be marked by paragraph.",
+			"aa\nbb", 5, "", func(s *Scanner) {
+				s.SetLastLineMode(LastLineOfParagraph)
+				s.SetLastLineSuffix("$")
+			},
+			"aa\nbb$",
+		},
+		{
+			"The last line prefix should replace the prefix.",
+			"aa bb cc", 7, "│ ", func(s *Scanner) { s.SetLastLinePrefix("└ ") },
+			"│ aa bb\n└ cc",
+		},
+		{
+			"No lines should be marked by default.",
+			"aa bb", 2, "", func(s *Scanner) {
+				s.SetLastLineMode(LastLineNone)
+				s.SetLastLineSuffix("$")
+				s.SetLastLineMode(LastLineNone)
+			},
+			"aa\nbb",
+		},
+		{
+			"The last line before truncation should be marked.",
+			"aa bb cc", 2, "", func(s *Scanner) {
+				s.SetMaxLines(2)
+				s.SetLastLineSuffix("$")
+			},
+			"aa\nbb$",
+		},
+		{
+			"Empty input should have no lines to mark.",
+			"", 5, "", func(s *Scanner) { s.SetLastLineSuffix("$") },
+			"",
+		},
+	},
+	"TabExpandFunc": {
+		{
+			"Tabs should advance to the column returned.",
+			"a\tb\tc", 20, "", func(s *Scanner) { s.SetTabExpandToColumnFunc(snapTo3) },
+			"a  b  c",
+		},
+		{
+			"Tabs at a stop should advance to the next.",
+			"abc\td", 20, "", func(s *Scanner) { s.SetTabExpandToColumnFunc(snapTo3) },
+			"abc   d",
+		},
+		{
+			"The function should override tab stops.",
+			"a\tb", 20, "", func(s *Scanner) {
+				s.SetTabStops([]int{8})
+				s.SetTabExpandToColumnFunc(snapTo3)
+			},
+			"a  b",
+		},
+		{
+			"Expanded tabs should count toward the limit.",
+			"ab\tcd\tef", 6, "", func(s *Scanner) { s.SetTabExpandToColumnFunc(snapTo3) },
+			"ab cd\nef",
+		},
+		{
+			"A column not past the tab should remove it.",
+			"a\tb", 20, "", func(s *Scanner) { s.SetTabExpandToColumnFunc(func(col int) int { return col }) },
+			"ab",
+		},
+		{
+			"Tabs should be rendered with the fill.",
+			"a\tb\tc", 20, "", func(s *Scanner) {
+				s.SetTabExpandToColumnFunc(snapTo3)
+				s.SetTabFillFunc(arrowFill)
+			},
+			"a→ b→ c",
+		},
+		{
+			"Tabs in the prefix should be rendered with the fill.",
+			"a b", 20, "\t", func(s *Scanner) { s.SetTabFillFunc(arrowFill) },
+			"→   a b",
+		},
+	},
+	"TrimLeadingAfterNewline": {
+		{
+			"Whitespace following a newline should be trimmed.",
+			"foo\n   bar", 10, "", func(s *Scanner) { s.SetTrimLeadingAfterNewline(true) },
+			"foo\nbar",
+		},
+		{
+			"Whitespace following a newline should be kept by default.",
+			"foo\n   bar", 10, "", nil,
+			"foo\n   bar",
+		},
+		{
+			"Tabs following a newline should be trimmed.",
+			"foo\n\t \tbar baz", 7, "", func(s *Scanner) { s.SetTrimLeadingAfterNewline(true) },
+			"foo\nbar baz",
+		},
+		{
+			"Whitespace at the start of input should be kept.",
+			"  foo\n  bar", 10, "", func(s *Scanner) { s.SetTrimLeadingAfterNewline(true) },
+			"  foo\nbar",
+		},
+		{
+			"Whitespace between words should be kept.",
+			"foo\n a  b", 10, "", func(s *Scanner) { s.SetTrimLeadingAfterNewline(true) },
+			"foo\na  b",
+		},
+		{
+			"Whitespace-only lines should become blank.",
+			"foo\n   \nbar", 10, "", func(s *Scanner) {
+				s.SetTrimLeadingAfterNewline(true)
+				s.SetTrimTrailingSpace(false)
+			},
+			"foo\n\nbar",
+		},
+		{
+			"Whitespace following a wrap should still be dropped.",
+			"foo bar\n  baz", 4, "", func(s *Scanner) { s.SetTrimLeadingAfterNewline(true) },
+			"foo\nbar\nbaz",
+		},
+	},
+	"LeadingTabs": {
+		{
+			"A tab beginning the input should be kept as indentation.",
+			"\tfoo bar", 8, "", nil,
+			"    foo\nbar",
+		},
+		{
+			"A tab following a newline should match one beginning the input.",
+			"x\n\tfoo bar", 8, "", nil,
+			"x\n    foo\nbar",
+		},
+		{
+			"A tab following a blank line should match one beginning the input.",
+			"x\n\n\tfoo bar", 8, "", nil,
+			"x\n\n    foo\nbar",
+		},
+		{
+			"A tab beginning the input should advance to a tab stop.",
+			"\tfoo bar", 8, "", func(s *Scanner) { s.SetTabStops([]int{2}) },
+			"  foo\nbar",
+		},
+		{
+			"A tab beginning the input should set the continuation indent.",
+			"\tfoo bar", 8, "", func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+			"    foo\n    bar",
+		},
+		{
+			"A tab beginning the input should follow the prefix.",
+			"\tfoo bar", 10, "> ", nil,
+			">     foo\n> bar",
+		},
+		{
+			"A tab beginning the input should be kept when buffering.",
+			"\tfoo bar", 8, "", func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			"    foo\nbar",
+		},
+		{
+			"Tabs beginning the input should be removed with the tab width.",
+			"\tfoo", 8, "", func(s *Scanner) { s.SetTabWidth(0) },
+			"foo",
+		},
+	},
+	"LineMode": {
+		{
+			"Each line of a log should wrap on its own.",
+			"INFO start\nWARN disk usage high on /var\n\nERROR connection refused by upstream\n", 16, "", func(s *Scanner) { s.SetLineMode(true) },
+			"INFO start\nWARN disk usage\nhigh on /var\n\nERROR connection\nrefused by\nupstream\n",
+		},
+		{
+			"Lines should not be reflowed.",
+			"foo\nbar", 10, "", func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetLineMode(true)
+			},
+			"foo\nbar",
+		},
+		{
+			"Blank lines should not be added between paragraphs.",
+			"foo\n\nbar", 10, "", func(s *Scanner) {
+				s.SetMinParagraphGap(2)
+				s.SetLineMode(true)
+			},
+			"foo\n\nbar",
+		},
+		{
+			"Directives should be kept as text.",
+			".right\nfoo", 10, "", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetLineMode(true)
+			},
+			".right\nfoo",
+		},
+		{
+			"Long words should wrap within their line.",
+			"abcdefgh\nij", 4, "", func(s *Scanner) { s.SetLineMode(true) },
+			"abcd\nefgh\nij",
+		},
+	},
+	"AlignPrefixToTab": {
+		{
+			"The prefix should be padded to the next tab stop.",
+			"a\tb", 20, "12 ", func(s *Scanner) { s.SetAlignPrefixToTab(true) },
+			"12  a   b",
+		},
+		{
+			"The prefix should not be padded by default.",
+			"a\tb", 20, "12 ", nil,
+			"12 a   b",
+		},
+		{
+			"A prefix ending on a tab stop should not be padded.",
+			"a\tb", 20, "123 ", func(s *Scanner) { s.SetAlignPrefixToTab(true) },
+			"123 a   b",
+		},
+		{
+			"The prefix should be padded to the tab width.",
+			"a\tb", 20, "12 ", func(s *Scanner) {
+				s.SetTabWidth(8)
+				s.SetAlignPrefixToTab(true)
+			},
+			"12      a       b",
+		},
+		{
+			"The padding should not count toward the limit.",
+			"aaaa bbbb", 4, "12 ", func(s *Scanner) { s.SetAlignPrefixToTab(true) },
+			"12  aaaa\n12  bbbb",
+		},
+		{
+			"Padding should be trimmed from blank lines.",
+			"a\n\nb", 20, "12 ", func(s *Scanner) {
+				s.SetAlignPrefixToTab(true)
+				s.SetPrefixOnBlankLines(true)
+				s.SetTrimPrefixOnBlank(true)
+			},
+			"12  a\n12\n12  b",
+		},
+		{
+			"The gutter separator should be padded with the prefix.",
+			"a", 20, "1", func(s *Scanner) {
+				s.SetGutterSeparator("| ", false)
+				s.SetAlignPrefixToTab(true)
+			},
+			"1|  a",
+		},
+		{
+			"An empty prefix should not be padded.",
+			"a\tb", 20, "", func(s *Scanner) { s.SetAlignPrefixToTab(true) },
+			"a   b",
+		},
+	},
+	"ZeroWidth": {
+		{
+			"Combining marks alone should fit on one line.",
+			strings.Repeat("\u0301\u0308", 1000), 3, "", nil,
+			strings.Repeat("\u0301\u0308", 1000),
+		},
+		{
+			"Combining marks alone should end at newlines.",
+			strings.Repeat("\u0301\u0308", 1000) + "\n" + strings.Repeat("\u0301\u0308", 1000), 3, "", nil,
+			strings.Repeat("\u0301\u0308", 1000) + "\n" + strings.Repeat("\u0301\u0308", 1000),
+		},
+		{
+			"Combining marks alone should be laid out whole.",
+			strings.Repeat("\u0301\u0308", 1000), 3, "", func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			strings.Repeat("\u0301\u0308", 1000),
+		},
+		{
+			"Combining marks alone should be placed anywhere.",
+			strings.Repeat("\u0301\u0308", 1000), 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			strings.Repeat("\u0301\u0308", 1000),
+		},
+		{
+			"Only the spaces between combining marks should count.",
+			"\u0301 \u0301 \u0301 \u0301", 3, "", nil,
+			"\u0301 \u0301 \u0301 \u0301",
+		},
+		{
+			"Joiners should take no columns.",
+			"\u200d\u0301\u200d\u0301\u200d\u0301\u200d", 3, "", nil,
+			"\u200d\u0301\u200d\u0301\u200d\u0301\u200d",
+		},
+		{
+			"Joiners alone should fit on one line.",
+			strings.Repeat("\u200d", 1000), 2, "", nil,
+			strings.Repeat("\u200d", 1000),
+		},
+		{
+			"Joiners alone should be placed anywhere.",
+			strings.Repeat("\u200d", 1000), 2, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			strings.Repeat("\u200d", 1000),
+		},
+	},
+	"KeepDigitGroups": {
+		{
+			"Arabic-Indic digits should be kept together.",
+			"ab١٢٣٤cd", 4, "", func(s *Scanner) { s.SetKeepDigitGroups(true) },
+			"ab\n١٢٣٤\ncd",
+		},
+		{
+			"Arabic-Indic digits should be broken by default.",
+			"ab١٢٣٤cd", 4, "", nil,
+			"ab١٢\n٣٤cd",
+		},
+		{
+			"A run wider than the limit should overflow it.",
+			"١٢٣٤٥٦", 4, "", func(s *Scanner) { s.SetKeepDigitGroups(true) },
+			"١٢٣٤٥٦",
+		},
+		{
+			"A run should be broken after when it begins the word.",
+			"x12345y", 3, "", func(s *Scanner) { s.SetKeepDigitGroups(true) },
+			"x\n12345\ny",
+		},
+		{
+			"Devanagari digits should be kept together.",
+			"ab १२३४", 5, "", func(s *Scanner) { s.SetKeepDigitGroups(true) },
+			"ab\n१२३४",
+		},
+		{
+			"Runs should be moved to the next line when breaking anywhere.",
+			"ab ١٢٣", 4, "", func(s *Scanner) {
+				s.SetBreakAnywhere(true)
+				s.SetKeepDigitGroups(true)
+			},
+			"ab\n١٢٣",
+		},
+		{
+			"Runs should be broken anywhere by default.",
+			"ab ١٢٣", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"ab ١\n٢٣",
+		},
+		{
+			"Arabic separators should divide kept numbers.",
+			"x ١٬٢٣٤٫٥", 4, "", func(s *Scanner) { s.SetKeepNumbers(true) },
+			"x\n١٬٢٣٤٫٥",
+		},
+	},
+	"TabFixedWidth": {
+		{
+			"A snapped tab should fit near the tab boundary.",
+			"ab\tcd", 6, "", nil,
+			"ab  cd",
+		},
+		{
+			"A fixed width tab should not fit near the tab boundary.",
+			"ab\tcd", 6, "", func(s *Scanner) { s.SetTabFixedWidth(true) },
+			"ab\ncd",
+		},
+		{
+			"A fixed width tab should expand to the tab width.",
+			"ab\tcd", 8, "", func(s *Scanner) { s.SetTabFixedWidth(true) },
+			"ab    cd",
+		},
+		{
+			"A fixed width tab at a stop should match a snapped one.",
+			"abcd\tef", 10, "", func(s *Scanner) { s.SetTabFixedWidth(true) },
+			"abcd    ef",
+		},
+		{
+			"Fixed width tabs should ignore tab stops.",
+			"a\tb", 10, "", func(s *Scanner) {
+				s.SetTabStops([]int{2})
+				s.SetTabFixedWidth(true)
+			},
+			"a    b",
+		},
+		{
+			"Fixed width tabs should follow the expand function.",
+			"a\tb", 10, "", func(s *Scanner) {
+				s.SetTabExpandToColumnFunc(snapTo3)
+				s.SetTabFixedWidth(true)
+			},
+			"a  b",
+		},
+		{
+			"Fixed width tabs should set the continuation indent.",
+			"a\tbb cc", 7, "", func(s *Scanner) {
+				s.SetTabFixedWidth(true)
+				s.SetAlignContinuationToTab(true)
+			},
+			"a    bb\n     cc",
+		},
+		{
+			"The prefix should still be padded to a tab stop.",
+			"a\tb", 20, "12 ", func(s *Scanner) {
+				s.SetTabFixedWidth(true)
+				s.SetAlignPrefixToTab(true)
+			},
+			"12  a    b",
+		},
+	},
+	"FillToColumn": {
+		{
+			"Lines should be filled to the column.",
+			"Introduction", 30, "", func(s *Scanner) { s.SetFillToColumn(20, '.') },
+			"Introduction........",
+		},
+		{
+			"The suffix should follow the fill.",
+			"Introduction", 30, "", func(s *Scanner) {
+				s.SetFillToColumn(20, '.')
+				s.SetLastLineSuffix(" 1")
+			},
+			"Introduction........ 1",
+		},
+		{
+			"Each line with text should be filled.",
+			"A rather long chapter title", 16, "", func(s *Scanner) { s.SetFillToColumn(16, '.') },
+			"A rather long...\nchapter title...",
+		},
+		{
+			"Blank lines should not be filled.",
+			"a\n\nb", 10, "", func(s *Scanner) { s.SetFillToColumn(4, '.') },
+			"a...\n\nb...",
+		},
+		{
+			"Lines reaching the column should not be filled.",
+			"abcd efgh", 10, "", func(s *Scanner) { s.SetFillToColumn(4, '.') },
+			"abcd efgh",
+		},
+		{
+			"The prefix should not count toward the column.",
+			"ab", 10, "> ", func(s *Scanner) { s.SetFillToColumn(4, '.') },
+			"> ab..",
+		},
+		{
+			"A wide fill should stop short of the column.",
+			"abc", 10, "", func(s *Scanner) { s.SetFillToColumn(6, '・') },
+			"abc・",
+		},
+		{
+			"The fill should follow aligned text.",
+			".right\nab", 6, "", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetFillToColumn(6, '.')
+			},
+			"    ab",
+		},
+	},
+	"DetectBullets": {
+		{
+			"Continuation lines should align after the bullet.",
+			"- a long bullet item that wraps", 12, "", func(s *Scanner) { s.SetDetectBullets(true) },
+			"- a long\n  bullet\n  item that\n  wraps",
+		},
+		{
+			"Bullets should not be detected by default.",
+			"- a long bullet item that wraps", 12, "", nil,
+			"- a long\nbullet item\nthat wraps",
+		},
+		{
+			"Indented bullets should align after the marker.",
+			"  * nested item that wraps", 12, "", func(s *Scanner) { s.SetDetectBullets(true) },
+			"  * nested\n    item\n    that\n    wraps",
+		},
+		{
+			"Numbered items should align after the number.",
+			"12. numbered item that wraps", 12, "", func(s *Scanner) { s.SetDetectBullets(true) },
+			"12. numbered\n    item\n    that\n    wraps",
+		},
+		{
+			"Words beginning with a marker should not be bullets.",
+			"-5 degrees is cold outside", 12, "", func(s *Scanner) { s.SetDetectBullets(true) },
+			"-5 degrees\nis cold\noutside",
+		},
+		{
+			"Reflowed items should be joined and aligned.",
+			"- first item that\nwraps here\n- second item", 12, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetReflow(true)
+			},
+			"- first item\n  that wraps\n  here\n- second\n  item",
+		},
+		{
+			"Items laid out whole should be aligned.",
+			"- a long bullet item that wraps", 12, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetBreakPreference(MinRagged)
+			},
+			"- a long\n  bullet\n  item that\n  wraps",
+		},
+		{
+			"Bullets should take precedence over tabs.",
+			"-\tx y z w v u", 12, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetAlignContinuationToTab(true)
+			},
+			"-   x y z w\n    v u",
+		},
+		{
+			"Items breaking anywhere should be aligned.",
+			"- a long bullet", 12, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetBreakAnywhere(true)
+			},
+			"- a long bul\n  let",
+		},
+		{
+			"Text not fitting after the marker should not be indented.",
+			"- abcdefghijkl mn", 8, "", func(s *Scanner) { s.SetDetectBullets(true) },
+			"-\nabcdefgh\nijkl mn",
+		},
+	},
+	"Verbatim": {
+		{
+			"All spacing should be kept, breaking only at the limit.",
+			"  foo   bar  ", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"  foo\n   ba\nr  ",
+		},
+		{
+			"Whitespace at a break should continue on the next line.",
+			"abcdefgh   ij", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"abcde\nfgh  \n ij",
+		},
+		{
+			"Trailing whitespace should wrap rather than be trimmed.",
+			"abcde \nx", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"abcde\n \nx",
+		},
+		{
+			"Blank lines should keep their whitespace.",
+			"   \n  ", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"   \n  ",
+		},
+		{
+			"Tabs should still be expanded.",
+			"a\tb", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"a   b",
+		},
+		{
+			"A tab which doesn't fit should be expanded on the next line.",
+			"abcd\tx", 5, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"abcd\n    x",
+		},
+		{
+			"Lines within the limit should be unchanged.",
+			"a  b\n  c  ", 8, "", func(s *Scanner) { s.SetVerbatim(true) },
+			"a  b\n  c  ",
+		},
+	},
+	"BreakHook": {
+		{
+			"A rejected break should let the word overflow.",
+			"hello a", 6, "", func(s *Scanner) { s.SetBreakHook(rejectShortNext) },
+			"hello a",
+		},
+		{
+			"An accepted break should be made.",
+			"hello ab", 6, "", func(s *Scanner) { s.SetBreakHook(rejectShortNext) },
+			"hello\nab",
+		},
+		{
+			"The next word which doesn't fit should be proposed in turn.",
+			"hello a bc", 6, "", func(s *Scanner) { s.SetBreakHook(rejectShortNext) },
+			"hello a\nbc",
+		},
+		{
+			"Breaks within long words should not be proposed.",
+			"abcdefgh", 4, "", func(s *Scanner) { s.SetBreakHook(func(ProposedBreak) bool { return false }) },
+			"abcd\nefgh",
+		},
+		{
+			"The hook should apply to lines laid out at once.",
+			"foo bar a baz", 7, "", func(s *Scanner) {
+				s.SetBreakHook(rejectShortNext)
+				s.SetPreferSentenceBreaks(true)
+			},
+			"foo bar a\nbaz",
+		},
+		{
+			"A prefix should not affect the proposal.",
+			"hello a", 6, "> ", func(s *Scanner) { s.SetBreakHook(rejectShortNext) },
+			"> hello a",
+		},
+	},
+	"NoLeadingFragment": {
+		{
+			"A word which fits on a line should begin the next line rather than be broken.",
+			"ab cdef", 5, "", func(s *Scanner) {
+				s.SetBreakAnywhere(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"ab\ncdef",
+		},
+		{
+			"A word as wide as the limit should not be broken.",
+			"ab cdefg", 5, "", func(s *Scanner) {
+				s.SetBreakAnywhere(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"ab\ncdefg",
+		},
+		{
+			"A word wider than the limit should still be broken.",
+			"ab cdefghij", 5, "", func(s *Scanner) {
+				s.SetBreakAnywhere(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"ab cd\nefghi\nj",
+		},
+		{
+			"A word which fits the limit but not the bullet indent should overflow.",
+			"- ab abcdefghi", 10, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"- ab\n  abcdefghi",
+		},
+		{
+			"A word wider than the limit should be broken after the bullet indent.",
+			"- ab abcdefghijkl", 10, "", func(s *Scanner) {
+				s.SetDetectBullets(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"- ab\n  abcdefgh\n  ijkl",
+		},
+		{
+			"A word which fits the limit but not the tab indent should overflow.",
+			"x:\tab abcdefgh", 10, "", func(s *Scanner) {
+				s.SetAlignContinuationToTab(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"x:  ab\n    abcdefgh",
+		},
+		{
+			"Words wider than the limit should be broken as usual.",
+			"abcde abcdef", 5, "", func(s *Scanner) { s.SetNoLeadingFragment(true) },
+			"abcde\nabcde\nf",
+		},
+		{
+			"Verbatim text should still break at the limit.",
+			"ab cdef", 5, "", func(s *Scanner) {
+				s.SetVerbatim(true)
+				s.SetNoLeadingFragment(true)
+			},
+			"ab cd\nef",
+		},
+	},
+	"GapChar": {
+		{
+			"Joined lines should be separated by the gap character.",
+			"foo\nbar", 10, "", func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetGapChar('·')
+			},
+			"foo·bar",
+		},
+		{
+			"Spaces from the input should be kept.",
+			"foo bar\nbaz", 12, "", func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetGapChar('·')
+			},
+			"foo bar·baz",
+		},
+		{
+			"Tabs should be expanded with the gap character.",
+			"a\tb", 10, "", func(s *Scanner) { s.SetGapChar('·') },
+			"a···b",
+		},
+		{
+			"A gap where a line breaks should be dropped.",
+			"foo\nbar", 5, "", func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetGapChar('·')
+			},
+			"foo\nbar",
+		},
+		{
+			"A thin space should be usable as the gap character.",
+			"foo\nbar", 10, "", func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetGapChar('\u2009')
+			},
+			"foo\u2009bar",
+		},
+	},
+	"MergePrefixPadding": {
+		{
+			"A right-aligned line should follow a single run of padding.",
+			".right\nabc", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+				s.SetDebugWhitespace(true)
+			},
+			">·······abc",
+		},
+		{
+			"The padding should absorb the prefix's whitespace.",
+			".right\nabc", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+			},
+			">       abc",
+		},
+		{
+			"Without merging, the padding should follow the prefix's whitespace.",
+			".right\nabc", 10, "> ", func(s *Scanner) { s.SetDirectives(true) },
+			">        abc",
+		},
+		{
+			"A centered line's padding should absorb the prefix's whitespace.",
+			".center\nabcd", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+			},
+			">   abcd",
+		},
+		{
+			"A line filling the limit should keep the prefix's whitespace.",
+			".right\nabcdefghij", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+			},
+			"> abcdefghij",
+		},
+		{
+			"Padding narrower than the prefix's whitespace should leave its width.",
+			".right\nabcdefghi", 10, ">   ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+			},
+			">   abcdefghi",
+		},
+		{
+			"Left-aligned lines should be unchanged.",
+			"abc", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+				s.SetDebugWhitespace(true)
+			},
+			"> abc",
+		},
+		{
+			"A hugging prefix should be unchanged.",
+			".right\nabc", 10, "> ", func(s *Scanner) {
+				s.SetDirectives(true)
+				s.SetMergePrefixPadding(true)
+				s.SetPrefixPlacement(PrefixHug)
+			},
+			"       > abc",
+		},
+	},
+	"Hyphenator": {
+		{
+			"A word should be hyphenated at a permitted point.",
+			"foobarbaz", 6, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(3)) },
+			"foo-\nbarbaz",
+		},
+		{
+			"The last point which fits should be used.",
+			"foobarbaz", 7, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(3, 6)) },
+			"foobar-\nbaz",
+		},
+		{
+			"The rest of a hyphenated word should be kept whole where it fits.",
+			"foobarbaz", 6, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(3, 6)) },
+			"foo-\nbarbaz",
+		},
+		{
+			"A word should be broken as usual where no point fits.",
+			"foobarbaz", 2, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(3)) },
+			"fo\no-\nba\nrb\naz",
+		},
+		{
+			"Points at the ends of the word should be ignored.",
+			"foobarbaz", 6, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(0, 9, 12, -1)) },
+			"foobar\nbaz",
+		},
+		{
+			"Words which fit should not be hyphenated.",
+			"foo bar", 7, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(1, 2)) },
+			"foo bar",
+		},
+		{
+			"Points should count runes.",
+			"日本語テキスト", 6, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(2)) },
+			"日本-\n語テキ\nスト",
+		},
+		{
+			"A hyphenated word should follow a line of its own.",
+			"ab foobarbaz", 6, "", func(s *Scanner) { s.SetHyphenator(hyphenateAt(3)) },
+			"ab\nfoo-\nbarbaz",
+		},
+	},
+	"GraphemeClusters": {
+		{
+			"Emoji joined into a family should take the width of one.",
+			"a👨\u200d👩\u200d👧b", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"a👨\u200d👩\u200d👧b",
+		},
+		{
+			"Emoji joined into a family should never be split.",
+			"a👨\u200d👩\u200d👧b", 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"a👨\u200d👩\u200d👧\nb",
+		},
+		{
+			"A family wider than the limit should overflow it whole.",
+			"a👨\u200d👩\u200d👧b", 1, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"a\n👨\u200d👩\u200d👧\nb",
+		},
+		{
+			"Regional indicators should be broken between flags.",
+			"🇺🇸🇬🇧🇫🇷", 5, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"🇺🇸🇬🇧\n🇫🇷",
+		},
+		{
+			"Flags should overflow rather than be split.",
+			"🇺🇸🇬🇧🇫🇷", 1, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"🇺🇸\n🇬🇧\n🇫🇷",
+		},
+		{
+			"Skin tone modifiers should stay with their emoji and take no columns.",
+			"👍🏽👍🏽x", 3, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"👍🏽\n👍🏽x",
+		},
+		{
+			"Combining marks should stay with their letter.",
+			"e\u0301e\u0301e\u0301", 2, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"e\u0301e\u0301\ne\u0301",
+		},
+		{
+			"Variation selectors should stay with their symbol.",
+			"❤\ufe0fab", 2, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"❤\ufe0fa\nb",
+		},
+	},
+	"TrailingTrim": {
+		{
+			"Trailing semicolons should be removed from wrapped statements.",
+			"a = 1; b = 2; c = 3;", 7, "", func(s *Scanner) { s.SetTrailingTrim(";") },
+			"a = 1\nb = 2\nc = 3",
+		},
+		{
+			"Whitespace uncovered by the cutset should be removed too.",
+			"x = 1; ;\ny = 2;  \n", 20, "", func(s *Scanner) { s.SetTrailingTrim(";") },
+			"x = 1\ny = 2\n",
+		},
+		{
+			"A line of nothing but the cutset should be left empty.",
+			";;;\nok;", 8, "", func(s *Scanner) { s.SetTrailingTrim(";") },
+			"\nok",
+		},
+		{
+			"Runes inside a line should be kept.",
+			"a;b; c;", 4, "", func(s *Scanner) { s.SetTrailingTrim(";") },
+			"a;b\nc",
+		},
+		{
+			"Kept trailing whitespace should shield the cutset.",
+			"x;  \ny;", 20, "", func(s *Scanner) { s.SetTrailingTrim(";"); s.SetTrimTrailingSpace(false) },
+			"x;  \ny",
+		},
+		{
+			"Trimmed lines should be aligned by their trimmed width.",
+			".right\na = 1; b = 2;", 8, "", func(s *Scanner) { s.SetTrailingTrim(";"); s.SetDirectives(true) },
+			"a = 1; b\n     = 2",
+		},
+	},
+	"QuoteAware": {
+		{
+			"A quoted phrase should be kept intact among other words.",
+			"echo 'a long quoted phrase' and more", 24, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"echo\n'a long quoted phrase'\nand more",
+		},
+		{
+			"Double quotes should be recognized too.",
+			"say \"hi there\" now", 10, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"say\n\"hi there\"\nnow",
+		},
+		{
+			"A quoted span should join the text attached to it.",
+			"cmd --name=\"x y\" --flag", 14, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"cmd\n--name=\"x y\"\n--flag",
+		},
+		{
+			"An escaped quote should not close the span.",
+			`say "a \"b c" d`, 10, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"say\n\"a \\\"b c\"\nd",
+		},
+		{
+			"A span wider than the limit should be broken at the limit.",
+			"echo 'a long quoted phrase'", 10, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"echo\n'a long qu\noted phras\ne'",
+		},
+		{
+			"Apostrophes should not open a span.",
+			"it's a dog's life", 6, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"it's a\ndog's\nlife",
+		},
+		{
+			"A span left open should be closed by a newline.",
+			"run 'open\nnext line here", 10, "", func(s *Scanner) { s.SetQuoteAware(true) },
+			"run 'open\nnext line\nhere",
+		},
+		{
+			"Break anywhere should move a span which fits to the next line.",
+			"echo 'a long quoted phrase' and more", 24, "", func(s *Scanner) { s.SetQuoteAware(true); s.SetBreakAnywhere(true) },
+			"echo\n'a long quoted phrase' a\nnd more",
+		},
+	},
+	"Replacer": {
+		{
+			"Words should be measured as replaced.",
+			"a->b c->d", 7, "", func(s *Scanner) { s.SetReplacer(strings.NewReplacer("->", "→")) },
+			"a→b c→d",
+		},
+		{
+			"Replaced words still wider than the limit should wrap.",
+			"a->b c->d", 6, "", func(s *Scanner) { s.SetReplacer(strings.NewReplacer("->", "→")) },
+			"a→b\nc→d",
+		},
+		{
+			"A replacement of ambiguous width should be measured as configured.",
+			"a->b c->d", 8, "", func(s *Scanner) { s.SetReplacer(strings.NewReplacer("->", "→")); s.SetAmbiguousWidth(2) },
+			"a→b\nc→d",
+		},
+		{
+			"Replacements should map smart quotes to ASCII.",
+			"“hi” there", 9, "", func(s *Scanner) { s.SetReplacer(strings.NewReplacer("“", `"`, "”", `"`)) },
+			"\"hi\"\nthere",
+		},
+		{
+			"Replaced words should be broken as replaced.",
+			"x->yyyyyy", 4, "", func(s *Scanner) { s.SetReplacer(strings.NewReplacer("->", "→")) },
+			"x→yy\nyyyy",
+		},
+	},
+	"HyphenationMinWordLen": {
+		{
+			"A word shorter than the threshold should overflow the limit.",
+			"ab abcdef cd", 5, "", func(s *Scanner) { s.SetHyphenationMinWordLen(10) },
+			"ab\nabcdef\ncd",
+		},
+		{
+			"A word at least as long as the threshold should be broken.",
+			"ab abcdefghijklmnopqrst cd", 5, "", func(s *Scanner) { s.SetHyphenationMinWordLen(10) },
+			"ab\nabcde\nfghij\nklmno\npqrst\ncd",
+		},
+		{
+			"Short words should overflow with paragraph layout too.",
+			"ab abcdef cd", 5, "", func(s *Scanner) { s.SetHyphenationMinWordLen(10); s.SetBreakPreference(MinRagged) },
+			"ab\nabcdef\ncd",
+		},
+		{
+			"Short words should not be hyphenated.",
+			"ab abcdef cd", 5, "", func(s *Scanner) {
+				s.SetHyphenationMinWordLen(10)
+				s.SetHyphenator(func(string) []int { return []int{3} })
+			},
+			"ab\nabcdef\ncd",
+		},
+		{
+			"Long words should still be hyphenated.",
+			"ab abcdefghij cd", 5, "", func(s *Scanner) {
+				s.SetHyphenationMinWordLen(10)
+				s.SetHyphenator(func(string) []int { return []int{3, 6} })
+			},
+			"ab\nabc-\ndef-\nghij\ncd",
+		},
+		{
+			"Runes rather than columns should be counted.",
+			"日本語です", 4, "", func(s *Scanner) { s.SetHyphenationMinWordLen(10) },
+			"日本語です",
+		},
+	},
+	"BreakAtEOF": {
+		{
+			"A line filling the limit at EOF should not be followed by an empty line.",
+			"abcd", 4, "", nil,
+			"abcd",
+		},
+		{
+			"Trailing space past the limit at EOF should not add a line.",
+			"abcd ", 4, "", nil,
+			"abcd",
+		},
+		{
+			"Trailing tabs past the limit at EOF should not add a line.",
+			"abcd   \t", 4, "", nil,
+			"abcd",
+		},
+		{
+			"A word ending at the limit at EOF should end the last line.",
+			"ab cd", 2, "", nil,
+			"ab\ncd",
+		},
+		{
+			"A broken word ending at EOF should not add a line.",
+			"abcdefgh", 4, "", nil,
+			"abcd\nefgh",
+		},
+		{
+			"A newline at EOF should still add an empty line.",
+			"abcd\n", 4, "", nil,
+			"abcd\n",
+		},
+		{
+			"Kept trailing space which doesn't fit should not add a line.",
+			"abcd ", 4, "", func(s *Scanner) { s.SetTrimTrailingSpace(false) },
+			"abcd",
+		},
+		{
+			"Break anywhere should not add a line at EOF.",
+			"abcdefgh ", 4, "", func(s *Scanner) { s.SetBreakAnywhere(true) },
+			"abcd\nefgh",
+		},
+		{
+			"Paragraph layout should not add a line at EOF.",
+			"ab cd ", 2, "", func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			"ab\ncd",
+		},
+	},
+}
+
+// snapTo3 advances tabs to the next multiple of 3.
+func snapTo3(col int) int {
+	return col/3*3 + 3
+}
+
+// arrowFill renders tabs as an arrow followed by spaces.
+func arrowFill(width int) string {
+	return "→" + strings.Repeat(" ", width-1)
+}
+
+func TestReadLine(t *testing.T) {
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				s := newTestScanner(c)
+
+				expected := strings.Split(c.expected, "\n")
+
+				var lines []string
+				for {
+					line, err := s.ReadLine()
+					if err == io.EOF {
+						break
+					}
+
+					require.NoError(t, err)
+					lines = append(lines, line)
+				}
+
+				assert.Equal(t, expected, lines, c.message)
+			}
+		})
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				s := newTestScanner(c)
+
+				buf := new(bytes.Buffer)
+				n, err := s.WriteTo(buf)
+				require.NoError(t, err)
+				assert.Equal(t, c.expected, buf.String(), c.message)
+				assert.Equal(t, len(c.expected), int(n), c.message)
+			}
+		})
+	}
+}
+
+func TestWriteToTrailingNewline(t *testing.T) {
+	cases := []struct {
+		text     string
+		lines    []string
+		expected string
+	}{
+		{"", []string{""}, ""},
+		{"\n", []string{"", ""}, "\n"},
+		{"foo", []string{"foo"}, "foo"},
+		{"foo\n", []string{"foo", ""}, "foo\n"},
+		{"foo\n\n", []string{"foo", "", ""}, "foo\n\n"},
+		{"foo bar", []string{"foo", "bar"}, "foo\nbar"},
+		{"foo bar\n", []string{"foo", "bar", ""}, "foo\nbar\n"},
+	}
+
+	for _, c := range cases {
+		var lines []string
+		s := NewScanner(strings.NewReader(c.text), 4)
+		for {
+			line, err := s.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			lines = append(lines, line)
+		}
+		assert.Equal(t, c.lines, lines, "ReadLine(%q)", c.text)
+
+		buf := new(bytes.Buffer)
+		_, err := NewScanner(strings.NewReader(c.text), 4).WriteTo(buf)
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, buf.String(), "WriteTo(%q)", c.text)
+		assert.Equal(t, strings.Join(lines, "\n"), buf.String(), "WriteTo(%q)", c.text)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := NewScanner(strings.NewReader("a\tb c\nlast line"), 8)
+	s.SetPrefix("> ")
+	s.SetTabWidth(2)
+
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "> a b c\n> last\n> line", text)
+
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "", text)
+}
+
+func TestReadLogicalLine(t *testing.T) {
+	const text = "one logical\tline wrapped into three\nnext line"
+
+	s := NewScanner(strings.NewReader(text), 12)
+	display, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "one logical", display)
+
+	s = NewScanner(strings.NewReader(text), 12)
+	logical, err := s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "one logical\tline wrapped into three", logical)
+
+	display, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "next line", display)
+
+	_, err = s.ReadLogicalLine()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadLogicalLineRemainder(t *testing.T) {
+	s := NewScanner(strings.NewReader("one logical line wrapped into three\nnext line"), 12)
+
+	display, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "one logical", display)
+
+	logical, err := s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "line wrapped into three", logical)
+
+	logical, err = s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "next line", logical)
+
+	_, err = s.ReadLogicalLine()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestUnwrap(t *testing.T) {
+	// HalfReader isn't an io.RuneScanner, so the Scanner must buffer it.
+	s := NewScanner(iotest.HalfReader(strings.NewReader("header line\nraw data")), 20)
+
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "header line", line)
+
+	rest, err := io.ReadAll(s.Unwrap())
+	require.NoError(t, err)
+	assert.Equal(t, "raw data", string(rest))
+}
+
+// flakyReader fails once with errFlaky after reading n bytes.
+type flakyReader struct {
+	r      io.Reader
+	n      int
+	failed bool
+}
+
+var errFlaky = errors.New("flaky")
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if !r.failed {
+		if r.n == 0 {
+			r.failed = true
+			return 0, errFlaky
+		}
+		if len(p) > r.n {
+			p = p[:r.n]
+		}
+	}
+	n, err := r.r.Read(p)
+	r.n -= n
+	return n, err
+}
+
+// stallingReader returns runes without consuming any input after reading n.
+type stallingReader struct {
+	*strings.Reader
+	n int
+}
+
+func (r *stallingReader) ReadRune() (rune, int, error) {
+	if r.n == 0 {
+		return 0, 0, nil
+	}
+	r.n--
+	return r.Reader.ReadRune()
+}
+
+// emptyReader returns no bytes and no error.
+type emptyReader struct{}
+
+func (emptyReader) Read(p []byte) (int, error) {
+	return 0, nil
+}
+
+func TestNoProgress(t *testing.T) {
+	s := NewScanner(&stallingReader{Reader: strings.NewReader("foo bar baz"), n: 8}, 4)
+	s.SetErrorHandler(func(err error) error { return nil })
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", line)
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, io.ErrNoProgress, "A reader which stalls should fail.")
+	var wrapErr *WrapError
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, 8, wrapErr.Offset)
+	assert.Equal(t, "bar ", wrapErr.Partial)
+
+	s = NewScanner(emptyReader{}, 4)
+	_, err = s.Drain()
+	assert.ErrorIs(t, err, io.ErrNoProgress, "An empty reader should fail.")
+}
+
+func TestErrorHandler(t *testing.T) {
+	var handled []error
+	s := NewScanner(&flakyReader{r: strings.NewReader("foo bar baz"), n: 5}, 4)
+	s.SetErrorHandler(func(err error) error {
+		handled = append(handled, err)
+		return nil
+	})
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\nbaz", text)
+	assert.Equal(t, []error{errFlaky}, handled)
+
+	// A replacement error should stop reading.
+	errAbort := errors.New("abort")
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo bar baz"), n: 5}, 4)
+	s.SetErrorHandler(func(err error) error { return errAbort })
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, errAbort)
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, errAbort)
+
+	// The look-ahead for CRLF should also consult the handler.
+	handled = nil
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo\r\nbar"), n: 4}, 4)
+	s.SetNormalizeNewlines(true, false)
+	s.SetErrorHandler(func(err error) error {
+		handled = append(handled, err)
+		return nil
+	})
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar", text)
+	assert.Equal(t, []error{errFlaky}, handled)
+
+	// Without a handler, the error should stop reading.
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo bar baz"), n: 5}, 4)
+	_, err = s.Drain()
+	assert.ErrorIs(t, err, errFlaky)
+
+	// An error during the CRLF look-ahead should not be dropped.
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo\r\nbar"), n: 4}, 4)
+	s.SetNormalizeNewlines(true, false)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", line)
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, errFlaky)
+}
+
+func TestWrapError(t *testing.T) {
+	s := NewScanner(&flakyReader{r: strings.NewReader("foo\nbär baz"), n: 9}, 10)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "foo", line)
+
+	_, err = s.ReadLine()
+	var wrapErr *WrapError
+	require.True(t, errors.As(err, &wrapErr), "The error should be a WrapError.")
+	assert.ErrorIs(t, err, errFlaky, "The reader's error should be unwrapped.")
+	assert.Equal(t, 9, wrapErr.Offset, "The offset should count bytes.")
+	assert.Equal(t, 8, wrapErr.Rune, "The rune offset should count runes.")
+	assert.Equal(t, "bär ", wrapErr.Partial, "The unreturned text should be included.")
+
+	_, err = s.ReadLine()
+	assert.Same(t, wrapErr, err, "The error should be repeated.")
+
+	// WriteTo should return the same error.
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo bar"), n: 5}, 10)
+	_, err = s.WriteTo(io.Discard)
+	require.True(t, errors.As(err, &wrapErr))
+	assert.ErrorIs(t, err, errFlaky)
+	assert.Equal(t, 5, wrapErr.Offset)
+	assert.Equal(t, "foo b", wrapErr.Partial)
+
+	// ReadLogicalLine should include the text it read.
+	s = NewScanner(&flakyReader{r: strings.NewReader("foo bar"), n: 5}, 10)
+	_, err = s.ReadLogicalLine()
+	require.True(t, errors.As(err, &wrapErr))
+	assert.Equal(t, "foo b", wrapErr.Partial)
+}
+
+func TestErr(t *testing.T) {
+	s := NewScanner(&flakyReader{r: strings.NewReader("foo bar"), n: 5}, 10)
+	assert.NoError(t, s.Err(), "There should be no error before reading.")
+	_, err := s.WriteTo(io.Discard)
+	require.Error(t, err)
+	assert.Same(t, err, s.Err(), "The read error should be kept.")
+	assert.ErrorIs(t, s.Err(), errFlaky)
+
+	s = NewScanner(strings.NewReader("foo bar"), 10)
+	_, err = s.Drain()
+	require.NoError(t, err)
+	assert.NoError(t, s.Err(), "EOF should not be an error.")
+
+	s = NewScanner(strings.NewReader("foo bar"), 10)
+	_, err = s.ReadLine()
+	require.NoError(t, err)
+	_, err = s.ReadLine()
+	require.Equal(t, io.EOF, err)
+	assert.NoError(t, s.Err(), "EOF from ReadLine should not be an error.")
+}
+
+func TestWriteToHash(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog."
+
+	s := NewScanner(strings.NewReader(text), 10)
+	expected, err := s.Drain()
+	require.NoError(t, err)
+	expectedSum := sha256.Sum256([]byte(expected))
+
+	buf := new(bytes.Buffer)
+	h := sha256.New()
+	s = NewScanner(strings.NewReader(text), 10)
+	n, err := s.WriteToHash(buf, h)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, len(expected), int(n))
+	assert.Equal(t, expectedSum[:], h.Sum(nil))
+}
+
+func TestChangePrefix(t *testing.T) {
+	s := NewScanner(strings.NewReader("foo bar baz"), 4)
+
+	s.SetPrefix("--")
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "--foo", line)
+
+	s.SetPrefix("+++")
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "+++bar", line)
+
+	s.SetPrefix("")
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "baz", line)
+}
+
+func TestChangePrefixPendingLine(t *testing.T) {
+	s := NewScanner(strings.NewReader("aaaa bbbb cccc"), 4)
+
+	// Reading the first line consumes the start of the second.
+	s.SetPrefix("1:")
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "1:aaaa", line)
+
+	// The pending continuation takes the prefix active when it's returned.
+	s.SetPrefix("2:")
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "2:bbbb", line)
+
+	s.SetPrefix("3:")
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "3:cccc", line)
+}
+
+func TestPrefixOnBlankLines(t *testing.T) {
+	const text = "first line\n\n  \nlast\n"
+
+	s := NewScanner(strings.NewReader(text), 20)
+	s.SetPrefix("> ")
+	s.SetPrefixOnBlankLines(true)
+	quoted, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "> first line\n> \n> \n> last\n", quoted)
+
+	s = NewScanner(strings.NewReader(text), 20)
+	s.SetPrefix("> ")
+	s.SetPrefixOnBlankLines(true)
+	s.SetTrimPrefixOnBlank(true)
+	quoted, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "> first line\n>\n>\n> last\n", quoted)
+}
+
+func TestPrefixFunc(t *testing.T) {
+	s := NewScanner(strings.NewReader("foo bar\n\nbaz"), 4)
+	s.SetPrefix("unused")
+	s.SetPrefixFunc(func(line int) string { return fmt.Sprintf("%d:", line) })
+
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "1:foo\n2:bar\n\n4:baz", text)
+}
+
+func TestReadLinePrefixed(t *testing.T) {
+	s := NewScanner(strings.NewReader("foo bar baz\n\nqux"), 4)
+	s.SetPrefix("> ")
+
+	line, err := s.ReadLinePrefixed("a1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "a1 foo", line)
+
+	line, err = s.ReadLinePrefixed("b2 ")
+	require.NoError(t, err)
+	assert.Equal(t, "b2 bar", line)
+
+	// The override applies only to a single line.
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "> baz", line)
+
+	line, err = s.ReadLinePrefixed("a4 ")
+	require.NoError(t, err)
+	assert.Equal(t, "", line)
+
+	line, err = s.ReadLinePrefixed("b5 ")
+	require.NoError(t, err)
+	assert.Equal(t, "b5 qux", line)
+
+	_, err = s.ReadLinePrefixed("unused")
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestZeroCopyLines(t *testing.T) {
+	// Lines taken from a string should match those copied from a reader.
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				s := newStringScanner(c.text, c.width)
+				s.SetPrefix(c.prefix)
+				if c.setup != nil {
+					c.setup(s)
+				}
+				got, err := s.Drain()
+				require.NoError(t, err)
+
+				expected, err := newTestScanner(c).Drain()
+				require.NoError(t, err)
+				assert.Equal(t, expected, got, c.message)
+			}
+		})
+	}
+
+	s := newStringScanner("a\tb c", 8)
+	s.SetTabWidth(4)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "a   b c", line, "Expanded tabs should be copied.")
+
+	// Lines laid out unchanged shouldn't be copied, saving an allocation each.
+	text := strings.Repeat("foo bar\n", 100)
+	shared := testing.AllocsPerRun(10, func() { newStringScanner(text, 8).ReadAll() })
+	copied := testing.AllocsPerRun(10, func() { NewScanner(strings.NewReader(text), 8).ReadAll() })
+	assert.Less(t, shared, copied-90, "Lines should share the text's memory.")
+}
+
+func BenchmarkReadLineFromString(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		newStringScanner(benchText, 40).ReadAll()
+	}
+}
+
+func BenchmarkReadLineCopying(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewScanner(strings.NewReader(benchText), 40).ReadAll()
+	}
+}
+
+// rejectShortNext is a break hook which rejects breaks leaving a word of one
+// character to begin the next line.
+func rejectShortNext(d ProposedBreak) bool {
+	return len(d.Word) > 1
+}
+
+func TestBreakHook(t *testing.T) {
+	var got []ProposedBreak
+	s := NewScanner(strings.NewReader("aa bb cc\n\tdd ee ff"), 7)
+	s.SetPrefix("> ")
+	s.SetAlignContinuationToTab(true)
+	s.SetBreakHook(func(d ProposedBreak) bool {
+		got = append(got, d)
+		return true
+	})
+	_, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, []ProposedBreak{
+		{Line: "aa bb", Word: "cc", Column: 5},
+		{Line: "    dd", Word: "ee", Column: 6},
+		{Line: "ee", Word: "ff", Column: 6},
+	}, got, "Columns should include the continuation indent.")
+}
+
+// hyphenateAt returns a hyphenator permitting the same points in every word.
+func hyphenateAt(points ...int) func(string) []int {
+	return func(string) []int { return points }
+}
+
+func TestHyphenatorWord(t *testing.T) {
+	var words []string
+	s := NewScanner(strings.NewReader("ab foobarbazqux"), 5)
+	s.SetHyphenator(func(word string) []int {
+		words = append(words, word)
+		return []int{3, 6, 9}
+	})
+	lines, err := s.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab", "foo-", "bar-", "baz-", "qux"}, lines)
+	assert.Equal(t, []string{"foobarbazqux"}, words, "The hyphenator should be given each word once, whole.")
+}
+
+func TestLineStarts(t *testing.T) {
+	const text = "The quick brown fox\n\n  jumps over the lazy dog.\nstupendous"
+	lines, err := NewScanner(strings.NewReader(text), 8).ReadAll()
+	require.NoError(t, err)
+
+	starts, err := NewScanner(strings.NewReader(text), 8).LineStarts()
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 4, 10, 16, 20, 21, 29, 38, 43, 48, 56}, starts)
+	require.Len(t, starts, len(lines))
+
+	// Wrapping from each offset should resume with the line beginning there.
+	for k, start := range starts {
+		rest, err := NewScanner(strings.NewReader(text[start:]), 8).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, lines[k:], rest, "Wrapping from line %d", k)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	const text = "aaa bbb ccc ddd"
+	expected, err := NewScanner(strings.NewReader(text), 4).Drain()
+	require.NoError(t, err)
+
+	s := NewScanner(strings.NewReader(text), 80)
+	s.SetLimit(4)
+	assert.Equal(t, 4, s.Limit())
+	got, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, expected, got, "SetLimit should match the limit given to NewScanner.")
+
+	s = NewScanner(strings.NewReader(text), 80)
+	s.SetWidth(4)
+	assert.Equal(t, 4, s.Limit())
+	got, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, expected, got, "SetWidth should match SetLimit.")
+
+	s = NewScanner(strings.NewReader(text+"\n"+text), 4)
+	assert.Equal(t, 4, s.Limit())
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "aaa", line)
+	s.SetLimit(8)
+	lines, err := s.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bbb ccc", "ddd", "aaa bbb", "ccc ddd"}, lines,
+		"Lines returned after the call should use the new limit.")
+}
+
+func TestOnLimitChange(t *testing.T) {
+	type change struct{ old, new int }
+	var changes []change
+	s := NewScanner(strings.NewReader("aaa bbb ccc ddd eee fff ggg hhh\niii"), 4)
+	s.SetOnLimitChange(func(old, new int) { changes = append(changes, change{old, new}) })
+
+	var lines []string
+	for _, limit := range []int{4, 8, 8, 12, 4} {
+		s.SetLimit(limit)
+		line, err := s.ReadLine()
+		require.NoError(t, err)
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"aaa", "bbb ccc", "ddd eee", "fff ggg hhh", "iii"}, lines,
+		"Each line should be laid out at its limit.")
+	assert.Equal(t, []change{{4, 8}, {8, 12}, {12, 4}}, changes,
+		"Each change of limit between lines should be reported once.")
+}
+
+func TestGutterSeparator(t *testing.T) {
+	gutter := func(line int) string { return fmt.Sprintf("%2d", line) }
+
+	s := NewScanner(strings.NewReader("some wrapped text"), 14)
+	s.SetPrefixFunc(gutter)
+	s.SetGutterSeparator(" │ ", false)
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, " 1 │ some wrapped\n 2 │ text", text)
+
+	s = NewScanner(strings.NewReader("some wrapped text"), 14)
+	s.SetPrefixFunc(gutter)
+	s.SetGutterSeparator(" │ ", true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, " 1 │ some\n 2 │ wrapped\n 3 │ text", text)
+
+	// A counted separator's display width is deducted, not its rune count.
+	s = NewScanner(strings.NewReader("aaaa bbbb"), 10)
+	s.SetGutterSeparator("｜", true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "｜aaaa\n｜bbbb", text)
+
+	s = NewScanner(strings.NewReader("aaaa bbb"), 10)
+	s.SetGutterSeparator("→ ", true)
+	s.SetAmbiguousWidth(2)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "→ aaaa\n→ bbb", text)
+
+	s = NewScanner(strings.NewReader("aaaa bbb"), 10)
+	s.SetGutterSeparator("→ ", true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "→ aaaa bbb", text)
+}
+
+func TestPrefixCountsTowardLimit(t *testing.T) {
+	s := NewScanner(strings.NewReader("aaa bbbb"), 10)
+	s.SetPrefix("＞ ")
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "＞ aaa bbbb", text, "The prefix should not count by default.")
+
+	// The fullwidth prefix takes 3 columns, though it's only 2 runes.
+	s = NewScanner(strings.NewReader("aaa bbbb"), 10)
+	s.SetPrefix("＞ ")
+	s.SetPrefixCountsTowardLimit(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "＞ aaa\n＞ bbbb", text)
+
+	s = NewScanner(strings.NewReader("ab cd"), 8)
+	s.SetPrefix("\t")
+	s.SetPrefixCountsTowardLimit(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "    ab\n    cd", text, "Tabs should be expanded before measuring.")
+
+	// A prefix wider than the limit leaves a single column.
+	s = NewScanner(strings.NewReader("ab cd"), 4)
+	s.SetPrefix("日本日本 ")
+	s.SetPrefixCountsTowardLimit(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "日本日本 a\n日本日本 b\n日本日本 c\n日本日本 d", text)
+
+	s = NewScanner(strings.NewReader("aaa bbbb"), 10)
+	s.SetPrefixFunc(func(int) string { return "＞ " })
+	s.SetPrefixCountsTowardLimit(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "＞ aaa bbbb", text, "A prefix from a func should not count.")
+}
+
+func TestControlCharMode(t *testing.T) {
+	cases := []struct {
+		mode     ControlCharMode
+		text     string
+		expected string
+	}{
+		{ControlCharPass, "a\x01b cd", "a\x01b\ncd"},
+		{ControlCharStrip, "a\x01b cd", "ab cd"},
+		{ControlCharCaret, "a\x01b cd", "a^Ab\ncd"},
+		{ControlCharPass, "a\x7fb cd", "a\x7fb\ncd"},
+		{ControlCharStrip, "a\x7fb cd", "ab cd"},
+		{ControlCharCaret, "a\x7fb cd", "a^?b\ncd"},
+		{ControlCharPass, "a\u0081b cd", "a\u0081b\ncd"},
+		{ControlCharStrip, "a\u0081b cd", "ab cd"},
+		{ControlCharCaret, "a\u0081b cd", "aM-^A\nb cd"},
+		{ControlCharCaret, "\u009f", "M-^_"},
+		{ControlCharCaret, "a\u0085b", "a\u0085b"},
+	}
+
+	for _, c := range cases {
+		s := NewScanner(strings.NewReader(c.text), 5)
+		s.SetControlCharMode(c.mode)
+		text, err := s.Drain()
+		require.NoError(t, err)
+		assert.Equal(t, c.expected, text, "mode %d, text %q", c.mode, c.text)
+	}
+}
+
+func TestReplaceNBSPWithSpace(t *testing.T) {
+	s := NewScanner(strings.NewReader("10\u00A0kg"), 8)
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "10\u00A0kg", text)
+
+	s = NewScanner(strings.NewReader("10\u00A0kg"), 8)
+	s.SetReplaceNBSPWithSpace(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "10 kg", text)
+
+	// No-break spaces should keep their words together either way.
+	s = NewScanner(strings.NewReader("a 10\u00A0kg"), 6)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "a\n10\u00A0kg", text)
+
+	s = NewScanner(strings.NewReader("a 10\u00A0kg"), 6)
+	s.SetReplaceNBSPWithSpace(true)
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "a\n10 kg", text)
+}
+
+func TestNormalizeNewlines(t *testing.T) {
+	const text = "one two\r\nthree\rfour\u0085five\u2028six\u2029seven\fend\r\n"
+
+	s := NewScanner(strings.NewReader(text), 20)
+	s.SetNormalizeNewlines(true, false)
+	out, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "one two\nthree\nfour\nfive\nsix\nseven\fend\n", out)
+
+	s = NewScanner(strings.NewReader(text), 20)
+	s.SetNormalizeNewlines(true, true)
+	out, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "one two\nthree\nfour\nfive\nsix\nseven\nend\n", out)
+
+	// Consecutive newlines of any convention should be kept.
+	s = NewScanner(strings.NewReader("a\r\n\r\rb\n\r\nc"), 20)
+	s.SetNormalizeNewlines(true, false)
+	out, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "a\n\n\nb\n\nc", out)
+
+	// Normalized newlines should wrap like "\n".
+	s = NewScanner(strings.NewReader("aaa bbb\rccc ddd"), 5)
+	s.SetNormalizeNewlines(true, false)
+	out, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "aaa\nbbb\nccc\nddd", out)
+}
+
+func TestChangeTabWidth(t *testing.T) {
+	s := NewScanner(strings.NewReader("first\tline\tnext\tline\tlast\tline"), 13)
+
+	s.SetTabWidth(4)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "first   line", line) // Before trim: "first   line    "
+
+	s.SetTabWidth(5)
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "next line", line) // Before trim: "next line "
+
+	s.SetTabWidth(0)
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "lastline", line)
+}
+
+func TestMaskLogicalLine(t *testing.T) {
+	s := NewScanner(strings.NewReader("secret word here\nnext line"), 8)
+	s.SetMaskChar('*')
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "******", line)
+
+	line, err = s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "**** ****", line, "The rest of the logical line should be masked.")
+
+	line, err = s.ReadLogicalLine()
+	require.NoError(t, err)
+	assert.Equal(t, "**** ****", line, "A raw logical line should be masked.")
+}
+
+func TestChangeMaskChar(t *testing.T) {
+	s := NewScanner(strings.NewReader("one two three"), 7)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "one two", line)
+
+	s.SetMaskChar('#')
+	line, err = s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "#####", line, "The mask should apply to the next line returned.")
+}
+
+func TestSetPrefixErr(t *testing.T) {
+	s := NewScanner(strings.NewReader("aa bb"), 6)
+	require.NoError(t, s.SetPrefixErr("> "))
+	assert.Equal(t, ErrPrefixNewline, s.SetPrefixErr(">\n"), "A newline should be rejected.")
+	assert.Equal(t, ErrPrefixNewline, s.SetPrefixErr(">\u2029"), "A paragraph separator should be rejected.")
+	assert.Error(t, s.SetPrefixErr("\t\t"), "A prefix should be measured with tabs expanded.")
+
+	got, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "> aa bb", got, "A rejected prefix should not replace the last one set.")
+}
+
+func TestReadLineMapped(t *testing.T) {
+	type mapped struct {
+		text       string
+		start, end int
+	}
+	cases := []struct {
+		message  string
+		text     string
+		width    int
+		setup    func(s *Scanner)
+		expected []mapped
+	}{
+		{
+			"Ranges should exclude whitespace trimmed at breaks.",
+			"foo bar  baz", 4, nil,
+			[]mapped{{"foo", 0, 3}, {"bar", 4, 7}, {"baz", 9, 12}},
+		},
+		{
+			"Ranges should include indentation and exclude newlines.",
+			"  foo\n\nbar\n", 8, nil,
+			[]mapped{{"  foo", 0, 5}, {"", 6, 6}, {"bar", 7, 10}, {"", 11, 11}},
+		},
+		{
+			"Broken words should divide their range.",
+			"日本語です", 4, nil,
+			[]mapped{{"日本", 0, 6}, {"語で", 6, 12}, {"す", 12, 15}},
+		},
+		{
+			"Escaped control characters should map to their source.",
+			"a\x01bcd", 3, func(s *Scanner) { s.SetControlCharMode(ControlCharCaret) },
+			[]mapped{{"a^A", 0, 2}, {"bcd", 2, 5}},
+		},
+		{
+			"Replaced words should map to their whole source.",
+			"a->b c x->yyyyyy", 4, func(s *Scanner) { s.SetReplacer(strings.NewReplacer("->", "→")) },
+			[]mapped{{"a→b", 0, 4}, {"c", 5, 6}, {"x→yy", 7, 16}, {"yyyy", 16, 16}},
+		},
+		{
+			"Reflowed lines should cover the newlines joined.",
+			"ab-\ncd ef\ngh", 8, func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetDehyphenate(true)
+			},
+			[]mapped{{"abcd ef", 0, 9}, {"gh", 10, 12}},
+		},
+		{
+			"CRLF should count as two bytes.",
+			"ab\r\ncd", 8, func(s *Scanner) { s.SetNormalizeNewlines(true, false) },
+			[]mapped{{"ab", 0, 2}, {"cd", 4, 6}},
+		},
+		{
+			"Lines laid out whole should be mapped.",
+			"aaa bb cc dddd", 7, func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+			[]mapped{{"aaa bb", 0, 6}, {"cc dddd", 7, 14}},
+		},
+		{
+			"Lines in line mode should not be joined by reflowing.",
+			"ab cd\nef", 8, func(s *Scanner) {
+				s.SetReflow(true)
+				s.SetLineMode(true)
+			},
+			[]mapped{{"ab cd", 0, 5}, {"ef", 6, 8}},
+		},
+		{
+			"Wrapped lines in line mode should map within their line.",
+			"ab cd\nef", 2, func(s *Scanner) { s.SetLineMode(true) },
+			[]mapped{{"ab", 0, 2}, {"cd", 3, 5}, {"ef", 6, 8}},
+		},
+	}
+
+	for _, c := range cases {
+		s := NewScanner(strings.NewReader(c.text), c.width)
+		if c.setup != nil {
+			c.setup(s)
+		}
+
+		var got []mapped
+		for {
+			text, start, end, err := s.ReadLineMapped()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, mapped{text, start, end})
+		}
+		assert.Equal(t, c.expected, got, c.message)
+	}
+}
+
+func TestReadLineMappedCoversInput(t *testing.T) {
+	const text = "  The quick\tbrown fox\n\njumps over the\n lazy dog. Supercalifragilistic\n"
+	setups := []func(s *Scanner){
+		nil,
+		func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+		func(s *Scanner) { s.SetReflow(true) },
+		func(s *Scanner) { s.SetAlignContinuationToTab(true) },
+		func(s *Scanner) { s.SetPrefix("> ") },
+	}
+
+	for i, setup := range setups {
+		s := NewScanner(strings.NewReader(text), 10)
+		if setup != nil {
+			setup(s)
+		}
+
+		prev := 0
+		for {
+			_, start, end, err := s.ReadLineMapped()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.True(t, prev <= start && start <= end, "setup %d: range [%d, %d) after %d", i, start, end, prev)
+			assert.Empty(t, strings.TrimSpace(text[prev:start]), "setup %d: only whitespace should be skipped", i)
+			prev = end
+		}
+		assert.Empty(t, strings.TrimSpace(text[prev:]), "setup %d: the input should be covered", i)
+	}
+}
+
+func TestStreaming(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog."
+
+	s := NewScanner(strings.NewReader(text), 10)
+	s.SetStreaming(true)
+	got, err := s.Drain()
+	require.NoError(t, err)
+	expected, err := NewScanner(strings.NewReader(text), 10).Drain()
+	require.NoError(t, err)
+	assert.Equal(t, expected, got, "Streaming should not change the output.")
+
+	buffering := map[string]func(s *Scanner){
+		"MinRagged":        func(s *Scanner) { s.SetBreakPreference(MinRagged) },
+		"BreakBeforeShort": func(s *Scanner) { s.SetBreakPreference(BreakBeforeShort) },
+		"MinWordsPerLine":  func(s *Scanner) { s.SetMinWordsPerLine(2) },
+		"TabIsWordBreak":   func(s *Scanner) { s.SetTabIsWordBreak(false) },
+	}
+	for name, setup := range buffering {
+		s := NewScanner(strings.NewReader(text), 10)
+		s.SetStreaming(true)
+		setup(s)
+		_, err := s.ReadLine()
+		assert.Equal(t, ErrStreaming, err, name)
+	}
+
+	// The error should clear once streaming is disabled.
+	s = NewScanner(strings.NewReader(text), 10)
+	s.SetStreaming(true)
+	s.SetBreakPreference(MinRagged)
+	_, err = s.ReadLine()
+	assert.Equal(t, ErrStreaming, err)
+	s.SetStreaming(false)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "The quick", line)
+}
+
+func TestTruncationSuffixCount(t *testing.T) {
+	var counts []int
+	suffix := func(n int) string {
+		counts = append(counts, n)
+		return ""
+	}
+
+	for _, max := range []int{1, 2, 3, 4} {
+		s := NewScanner(strings.NewReader("a b c d\n"), 1)
+		s.SetMaxLines(max)
+		s.SetTruncationSuffix(suffix)
+		_, err := s.Drain()
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{3, 2, 1}, counts, "The suffix should only be computed when lines are dropped.")
+}
+
+func TestStats(t *testing.T) {
+	s := NewScanner(strings.NewReader("ab abcdefg\nc"), 4)
+	s.SetCollectStats(true)
+	_, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Runes: 12, Lines: 4, ForcedBreaks: 1, WidthCalls: 18}, s.Stats())
+
+	s = NewScanner(strings.NewReader("ab abcdefg\nc"), 4)
+	_, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Runes: 12, Lines: 4}, s.Stats(), "Only free counts should be kept by default.")
+
+	s, err = ScannerFromConfig(strings.NewReader("abcde"), Config{Limit: 2, CollectStats: true})
+	require.NoError(t, err)
+	_, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Runes: 5, Lines: 3, ForcedBreaks: 2, WidthCalls: 12}, s.Stats(), "Stats should be collected from a Config.")
+}
+
+func TestFootnoteReader(t *testing.T) {
+	s := NewScanner(strings.NewReader("ab * cd"), 4)
+	s.SetFootnoteReader(strings.NewReader("note"), '*')
+	var got []string
+	for {
+		line, start, end, err := s.ReadLineMapped()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		got = append(got, fmt.Sprintf("%s %d-%d", line, start, end))
+	}
+	assert.Equal(t, []string{"ab 0-2", "note 4-4", "cd 5-7"}, got, "Footnote text should map to the end of its marker.")
+
+	s = NewScanner(strings.NewReader("ab * cd"), 10)
+	s.SetFootnoteReader(&flakyReader{r: strings.NewReader("note"), n: 0}, '*')
+	_, err := s.ReadLine()
+	assert.ErrorIs(t, err, errFlaky, "Errors reading footnotes should be returned.")
+}
+
+func TestColumnGuidesWidth(t *testing.T) {
+	const text = "The 日本語 text wraps, with ｆｕｌｌｗｉｄｔｈ letters and\n\nblank lines."
+	cfg := Config{}
+	for limit := 2; limit <= 12; limit++ {
+		s := NewScanner(strings.NewReader(text), limit)
+		s.SetColumnGuides(true)
+		s.SetPadBlankLines(true)
+		for {
+			line, err := s.ReadLine()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			assert.Equal(t, limit, cfg.stringWidth(line), "Line %q should fill %d columns.", line, limit)
+		}
+	}
+}
+
+func TestStrictWidth(t *testing.T) {
+	s := NewScanner(strings.NewReader("see 1,234,567,890 and more\nok"), 8)
+	s.SetKeepNumbers(true)
+	s.SetStrictWidth(true)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "see", line)
+
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, ErrLineTooWide, "An unbreakable token wider than the limit should be an error.")
+	var lineErr *LineWidthError
+	require.ErrorAs(t, err, &lineErr)
+	assert.Equal(t, LineWidthError{Line: 2, Text: "1,234,567,890", Width: 13, Limit: 8}, *lineErr)
+
+	lines, err := s.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"and more", "ok"}, lines, "Reading should continue after the line.")
+
+	s = NewScanner(strings.NewReader("ab abcdef cd"), 5)
+	s.SetHyphenationMinWordLen(10)
+	s.SetStrictWidth(true)
+	var b strings.Builder
+	_, err = s.WriteTo(&b)
+	assert.ErrorIs(t, err, ErrLineTooWide, "WriteTo should stop at the line.")
+	assert.Equal(t, "ab", b.String())
+
+	s = NewScanner(strings.NewReader("a👨\u200d👩\u200d👧b 👍🏽x"), 4)
+	s.SetStrictWidth(true)
+	_, err = s.ReadAll()
+	assert.NoError(t, err, "Emoji sequences should be measured as a single emoji.")
+
+	s = NewScanner(strings.NewReader("日本"), 1)
+	s.SetStrictWidth(true)
+	_, err = s.ReadLine()
+	assert.ErrorIs(t, err, ErrLineTooWide, "A character wider than the limit should be an error.")
+
+	s = NewScanner(strings.NewReader("a long line of words\n\tindented"), 8)
+	s.SetPrefix("a long prefix: ")
+	s.SetStrictWidth(true)
+	_, err = s.ReadAll()
+	assert.NoError(t, err, "Lines within the limit should pass, however long the prefix.")
+
+	s = NewScanner(strings.NewReader("1,234,567,890"), 8)
+	s.SetKeepNumbers(true)
+	lines, err = s.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1,234,567,890"}, lines, "Without strict width, the line should overflow.")
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	cases := []struct {
+		message  string
+		text     string
+		max      int64
+		setup    func(s *Scanner)
+		expected string
+		err      error
+	}{
+		{"Output should stop mid-line.", "hello world", 8, nil, "hello wo", ErrOutputLimit},
+		{"A multibyte rune should not be split.", "héllo", 2, nil, "h", ErrOutputLimit},
+		{"A line's prefix should not be split.", "ab\ncd", 6, func(s *Scanner) { s.SetPrefix("> ") }, "> ab\n", ErrOutputLimit},
+		{"A prefix which fits should be written.", "ab\ncd", 8, func(s *Scanner) { s.SetPrefix("> ") }, "> ab\n> c", ErrOutputLimit},
+		{"A newline which doesn't fit should not be written.", "ab\ncd", 2, nil, "ab", ErrOutputLimit},
+		{"An escaped character should not be split.", "a&b", 3, func(s *Scanner) { s.SetHTMLOutput(true) }, "a", ErrOutputLimit},
+		{"An HTML line break should not be split.", "a\nb", 4, func(s *Scanner) { s.SetHTMLOutput(true) }, "a", ErrOutputLimit},
+		{"Output which fits exactly should not be cut.", "ab\ncd", 5, nil, "ab\ncd", nil},
+		{"Zero should not limit the output.", "ab\ncd", 0, nil, "ab\ncd", nil},
+	}
+
+	for _, c := range cases {
+		s := NewScanner(strings.NewReader(c.text), 20)
+		s.SetMaxOutputBytes(c.max)
+		if c.setup != nil {
+			c.setup(s)
+		}
+		var buf bytes.Buffer
+		n, err := s.WriteTo(&buf)
+		assert.Equal(t, c.err, err, c.message)
+		assert.Equal(t, c.expected, buf.String(), c.message)
+		assert.Equal(t, int64(buf.Len()), n, c.message)
+		if c.max > 0 {
+			assert.LessOrEqual(t, n, c.max, c.message)
+		}
+	}
+}
+
+func TestHTMLOutput(t *testing.T) {
+	s := NewScanner(strings.NewReader("if a < b && c > d\n"), 8)
+	s.SetHTMLOutput(true)
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	require.NoError(t, err)
+	const expected = "if a &lt; b<br>\n&amp;&amp; c &gt; d<br>\n"
+	assert.Equal(t, expected, buf.String())
+	assert.Equal(t, int64(len(expected)), n, "The count should include escapes and breaks.")
+
+	s = NewScanner(strings.NewReader("<p> ok"), 3)
+	s.SetHTMLOutput(true)
+	s.SetHTMLLineBreak("<br/>")
+	text, err := s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "&lt;p&gt;<br/>ok", text, "The line break should be configurable.")
+
+	s = NewScanner(strings.NewReader("<p> ok"), 3)
+	s.SetPrefix("> ")
+	s.SetHTMLOutput(true)
+	line, err := s.ReadLine()
+	require.NoError(t, err)
+	assert.Equal(t, "> <p>", line, "ReadLine should not escape.")
+	text, err = s.Drain()
+	require.NoError(t, err)
+	assert.Equal(t, "&gt; ok", text, "The prefix should be escaped.")
+}
+
+func TestReadAll(t *testing.T) {
+	for name, cases := range allCases {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range cases {
+				lines, err := newTestScanner(c).ReadAll()
+				require.NoError(t, err)
+				assert.Equal(t, strings.Split(c.expected, "\n"), lines, c.message)
+			}
+		})
+	}
+
+	s := NewScanner(&flakyReader{r: strings.NewReader("foo\nbar baz"), n: 6}, 10)
+	lines, err := s.ReadAll()
+	assert.ErrorIs(t, err, errFlaky, "The reader's error should be returned.")
+	assert.Equal(t, []string{"foo"}, lines, "Lines before the error should be returned.")
 }
@@ -0,0 +1,270 @@
+package wordwrap
+
+import (
+	"io"
+	"math"
+	"strings"
+)
+
+// Wrapper holds a configuration for creating Scanners. Unlike a Scanner, it
+// holds no per-read state, so a single Wrapper may be defined once and used
+// concurrently. Each Scanner it creates should still be used by only one
+// goroutine at a time.
+type Wrapper struct {
+	cfg Config
+}
+
+// NewWrapper creates a Wrapper from the given configuration. It returns an
+// error if the configuration is invalid.
+func NewWrapper(cfg Config) (*Wrapper, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Wrapper{cfg: cfg.clone()}, nil
+}
+
+// Config returns the Wrapper's configuration.
+func (w *Wrapper) Config() Config {
+	return w.cfg.clone()
+}
+
+// NewScanner creates a Scanner over the given reader using the Wrapper's
+// configuration. As with the package-level NewScanner, the new Scanner takes
+// ownership of the reader.
+func (w *Wrapper) NewScanner(r io.Reader) *Scanner {
+	s := NewScanner(r, w.cfg.Limit)
+	s.cfg = w.cfg.clone()
+	s.SetCollectStats(w.cfg.CollectStats)
+	return s
+}
+
+// newStringScanner creates a Scanner over text using the Wrapper's
+// configuration, returning lines which needn't be altered as substrings of
+// text.
+func (w *Wrapper) newStringScanner(text string) *Scanner {
+	s := w.NewScanner(strings.NewReader(text))
+	s.src = text
+	return s
+}
+
+// Wrap wraps text, returning the lines joined by newlines.
+func (w *Wrapper) Wrap(text string) string {
+	// Reading from a string can't fail.
+	wrapped, _ := w.newStringScanner(text).Drain()
+	return wrapped
+}
+
+// WrapTo wraps text, writing the lines joined by newlines to dst as WriteTo
+// does. It returns the number of bytes written and any error from dst.
+func (w *Wrapper) WrapTo(dst io.Writer, text string) (int64, error) {
+	return w.newStringScanner(text).WriteTo(dst)
+}
+
+// Lines wraps text, returning each line separately. As with ReadLine, each
+// trailing newline of text adds an empty line to the result. Lines which needn't
+// be altered, such as by expanding tabs, share text's memory rather than being
+// copied.
+func (w *Wrapper) Lines(text string) []string {
+	// Reading from a string can't fail.
+	lines, _ := w.newStringScanner(text).ReadAll()
+	return lines
+}
+
+// WrapTo wraps text to the given limit using the default configuration, writing
+// the lines joined by newlines to w without building the result in memory. It
+// returns the number of bytes written and any error from w.
+func WrapTo(w io.Writer, text string, limit int) (int64, error) {
+	return newStringScanner(text, limit).WriteTo(w)
+}
+
+// WrapFunc wraps text to the given limit using the default configuration,
+// mapping each line to a value with f rather than building a slice of strings.
+// The wrapped argument reports whether the line was broken to fit the limit,
+// continuing on the next line, rather than ending at a newline or the end of
+// text. As with ReadLine, each trailing newline of text adds an empty line.
+func WrapFunc[T any](text string, limit int, f func(line string, wrapped bool) T) []T {
+	s := newStringScanner(text, limit)
+	var out []T
+	for {
+		line, err := s.nextLine()
+		if err != nil {
+			return out
+		}
+		out = append(out, f(s.decorate(line, s.linePrefix(line)), line.wrapped()))
+	}
+}
+
+// WrapParagraphGroups wraps text to the given limit using the default
+// configuration, returning the lines of each paragraph as a group. Paragraphs
+// are separated by blank lines, and each blank line ends a group, so every
+// blank line after the first between two paragraphs adds an empty group, as do
+// blank lines at the start or end of text. A single trailing newline ends the
+// last paragraph without adding a group. Empty text yields no groups.
+func WrapParagraphGroups(text string, limit int) [][]string {
+	s := newStringScanner(text, limit)
+	var groups [][]string
+	group := []string{}
+	for {
+		line, err := s.nextLine()
+		if err != nil {
+			break
+		}
+		switch text := s.decorate(line, s.linePrefix(line)); {
+		case text != "":
+			group = append(group, text)
+		case line.brk != breakEOF:
+			groups = append(groups, group)
+			group = []string{}
+		}
+	}
+	if text == "" {
+		return nil
+	}
+	return append(groups, group)
+}
+
+// WrapColumns wraps each of texts to the width of the same index, using the
+// default configuration, and lays the results out side by side. Each row joins
+// a line of each column, separated by gap spaces, with every column but the
+// last padded to its width so the columns stay aligned. Where a column runs out
+// of lines before the others, it's padded with spaces. Rows aren't padded past
+// their last text. A text without a width isn't wrapped, and its column is as
+// wide as its widest line.
+func WrapColumns(texts []string, widths []int, gap int) []string {
+	cfg := Config{}
+	cols := make([][]string, len(texts))
+	colWidths := make([]int, len(texts))
+	rows := 0
+	for i, text := range texts {
+		if i < len(widths) {
+			cols[i], _ = newStringScanner(text, widths[i]).ReadAll()
+			colWidths[i] = widths[i]
+		} else {
+			cols[i], _ = newStringScanner(text, math.MaxInt32).ReadAll()
+			for _, line := range cols[i] {
+				colWidths[i] = maxInt(colWidths[i], cfg.stringWidth(line))
+			}
+		}
+		if len(cols[i]) > rows {
+			rows = len(cols[i])
+		}
+	}
+
+	sep := strings.Repeat(" ", gap)
+	out := make([]string, rows)
+	for r := range out {
+		var b strings.Builder
+		for i, col := range cols {
+			line := ""
+			if r < len(col) {
+				line = col[r]
+			}
+			if i > 0 {
+				b.WriteString(sep)
+			}
+			b.WriteString(line)
+			if i < len(cols)-1 {
+				if n := colWidths[i] - cfg.stringWidth(line); n > 0 {
+					b.WriteString(strings.Repeat(" ", n))
+				}
+			}
+		}
+		out[r] = strings.TrimRight(b.String(), " ")
+	}
+	return out
+}
+
+// WideFiller fills the cell following a wide rune in a grid from WrapGrid, as
+// the rune occupies both cells.
+const WideFiller rune = 0
+
+// WrapGrid wraps text to cols columns using the default configuration, laying
+// the lines out in a grid of rows by cols runes, as for a terminal screen. Lines
+// past the last row are dropped, and cells past the end of each line or of the
+// text are spaces. A wide rune occupies two cells, its own followed by
+// WideFiller. Runes occupying no columns, such as combining marks and emoji
+// joined to the one before them, are dropped, as a cell holds a single rune. A
+// line is only wider than cols where a single rune is, such as a wide rune when
+// cols is 1. Such a line is cut before the first rune which doesn't fit rather
+// than pushing it to the next row, leaving the rest of its row blank. If cols or
+// rows is less than 1, the grid is nil.
+func WrapGrid(text string, cols, rows int) [][]rune {
+	if cols < 1 || rows < 1 {
+		return nil
+	}
+
+	cfg := Config{}
+	s := newStringScanner(text, cols)
+	grid := make([][]rune, rows)
+	for r := range grid {
+		row := make([]rune, cols)
+		for c := range row {
+			row[c] = ' '
+		}
+		grid[r] = row
+
+		// Once the text ends, the remaining rows are left blank.
+		line, _ := s.ReadLine()
+		c := 0
+		var cl cluster
+		for _, char := range line {
+			w := 0
+			if cl.breaksBefore(char) || isRegionalIndicator(char) {
+				w = cfg.runeWidth(char)
+			}
+			if w == 0 {
+				continue
+			} else if c+w > cols {
+				break
+			}
+			row[c] = char
+			for i := 1; i < w; i++ {
+				row[c+i] = WideFiller
+			}
+			c += w
+		}
+	}
+	return grid
+}
+
+// FitLines returns the narrowest limit at which text wraps, with the default
+// configuration, into at most maxLines lines. The limit is found by binary
+// search between 1 and the width of the widest line of text. If text can't fit
+// in maxLines lines at any limit, as when it has more lines of input than that,
+// it returns the width of its widest line, at which it wraps into the fewest
+// lines. A maxLines below 1 is treated as 1.
+func FitLines(text string, maxLines int) int {
+	// Unwrapped, each line of input is a line of output.
+	widest := 1
+	cfg := Config{}
+	lines, _ := newStringScanner(text, math.MaxInt32).ReadAll()
+	for _, line := range lines {
+		if w := cfg.stringWidth(line); w > widest {
+			widest = w
+		}
+	}
+
+	lo, hi := 1, widest
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if countLines(text, mid) <= maxLines {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+// countLines returns the number of lines text wraps into at the given limit
+// with the default configuration.
+func countLines(text string, limit int) int {
+	s := newStringScanner(text, limit)
+	n := 0
+	for {
+		if _, err := s.ReadLine(); err != nil {
+			return n
+		}
+		n++
+	}
+}
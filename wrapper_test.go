@@ -0,0 +1,215 @@
+package wordwrap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapper(t *testing.T) {
+	w, err := NewWrapper(Config{Limit: 8, Prefix: "> ", TabWidth: DefaultTabWidth})
+	require.NoError(t, err)
+
+	assert.Equal(t, "> foo bar\n> baz", w.Wrap("foo bar baz"))
+	assert.Equal(t, []string{"> foo bar", "> baz"}, w.Lines("foo bar baz"))
+	assert.Equal(t, []string{""}, w.Lines(""))
+}
+
+// errWriter fails every write with errWrite.
+type errWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (errWriter) Write(p []byte) (int, error) { return 0, errWrite }
+
+func TestWrapTo(t *testing.T) {
+	const text = "The quick brown fox\tjumps over the lazy dog.\n"
+
+	w, err := NewWrapper(Config{Limit: 10})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := WrapTo(&buf, text, 10)
+	require.NoError(t, err)
+	assert.Equal(t, w.Wrap(text), buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+
+	buf.Reset()
+	n, err = w.WrapTo(&buf, text)
+	require.NoError(t, err)
+	assert.Equal(t, w.Wrap(text), buf.String())
+	assert.Equal(t, int64(buf.Len()), n)
+
+	_, err = WrapTo(errWriter{}, text, 10)
+	assert.Equal(t, errWrite, err)
+}
+
+func TestLinesTrailingNewlines(t *testing.T) {
+	w, err := NewWrapper(Config{Limit: 8})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"foo"}, w.Lines("foo"))
+	assert.Equal(t, []string{"foo", ""}, w.Lines("foo\n"))
+	assert.Equal(t, []string{"foo", "", ""}, w.Lines("foo\n\n"))
+	assert.Equal(t, []string{"foo", "", "", ""}, w.Lines("foo\n\n\n"))
+
+	for _, text := range []string{"foo", "foo\n", "foo\n\n", "foo\n\n\n"} {
+		var buf bytes.Buffer
+		_, err := w.WrapTo(&buf, text)
+		require.NoError(t, err)
+		assert.Equal(t, text, buf.String(), "WriteTo should round-trip the newlines of %q", text)
+	}
+}
+
+func TestInvalidWrapper(t *testing.T) {
+	w, err := NewWrapper(Config{Limit: 0})
+	assert.Error(t, err)
+	assert.Nil(t, w)
+}
+
+func TestWrapperConcurrent(t *testing.T) {
+	w, err := NewWrapper(Config{Limit: 10, Prefix: "> ", TabWidth: DefaultTabWidth})
+	require.NoError(t, err)
+
+	const n = 32
+	results := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = w.Wrap(fmt.Sprintf("item %d wraps onto two lines", i))
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		assert.Equal(t, fmt.Sprintf("> item %d\n> wraps onto\n> two lines", i), result)
+	}
+}
+
+func TestWrapFunc(t *testing.T) {
+	type line struct {
+		text    string
+		wrapped bool
+	}
+	toLine := func(text string, wrapped bool) line { return line{text, wrapped} }
+
+	lines := WrapFunc("some wrapped text\nabcdefgh\n", 7, toLine)
+	assert.Equal(t, []line{
+		{"some", true},
+		{"wrapped", true},
+		{"text", false},
+		{"abcdefg", true},
+		{"h", false},
+		{"", false},
+	}, lines)
+
+	widths := WrapFunc("a bb ccc", 4, func(text string, _ bool) int { return len(text) })
+	assert.Equal(t, []int{4, 3}, widths)
+
+	assert.Equal(t, []line{{"", false}}, WrapFunc("", 4, toLine), "Empty text should yield an empty line.")
+}
+
+func TestWrapParagraphGroups(t *testing.T) {
+	assert.Equal(t, [][]string{
+		{"The quick", "brown fox."},
+		{"Jumps over", "the dog."},
+	}, WrapParagraphGroups("The quick brown fox.\n\nJumps over the dog.\n", 10))
+
+	assert.Equal(t, [][]string{{"a"}, {}, {"b"}}, WrapParagraphGroups("a\n\n\nb", 10),
+		"Each further blank line should add an empty group.")
+	assert.Equal(t, [][]string{{}, {"a"}, {}}, WrapParagraphGroups("\na\n\n", 10),
+		"Blank lines at either end should add empty groups.")
+	assert.Equal(t, [][]string{{"a", "b"}}, WrapParagraphGroups("a\nb", 10),
+		"Lines of a paragraph should share a group.")
+	assert.Nil(t, WrapParagraphGroups("", 10), "Empty text should yield no groups.")
+}
+
+func TestWrapGrid(t *testing.T) {
+	grid := WrapGrid("The quick brown fox jumps.", 6, 4)
+	assert.Equal(t, []string{"The   ", "quick ", "brown ", "fox   "}, gridRows(grid),
+		"Lines should be padded to the columns and cut to the rows.")
+
+	assert.Equal(t, []string{"ab    ", "      ", "      "}, gridRows(WrapGrid("ab", 6, 3)),
+		"Rows past the text should be blank.")
+
+	assert.Equal(t, [][]rune{
+		{'日', WideFiller, '本', WideFiller},
+		{'語', WideFiller, 'a', ' '},
+	}, WrapGrid("日本語a", 4, 2), "Wide runes should fill two cells.")
+
+	assert.Equal(t, [][]rune{{'a', '日', WideFiller}, {'b', ' ', ' '}}, WrapGrid("a日 b", 3, 2),
+		"A wide rune should be able to end a row.")
+
+	assert.Equal(t, [][]rune{{' '}, {'a'}}, WrapGrid("日 a", 1, 2),
+		"A wide rune wider than the row should be cut.")
+
+	assert.Equal(t, []string{"e  "}, gridRows(WrapGrid("e\u0301", 3, 1)),
+		"Combining marks should be dropped.")
+
+	assert.Equal(t, [][]rune{{'a', '👨', WideFiller, 'b'}}, WrapGrid("a👨\u200d👩\u200d👧b", 4, 1),
+		"An emoji sequence should fill the cells of its first emoji.")
+
+	assert.Nil(t, WrapGrid("text", 0, 2), "A grid without columns should be nil.")
+	assert.Nil(t, WrapGrid("text", 2, 0), "A grid without rows should be nil.")
+}
+
+// gridRows converts the rows of a grid without wide runes to strings.
+func gridRows(grid [][]rune) []string {
+	rows := make([]string, len(grid))
+	for i, row := range grid {
+		rows[i] = string(row)
+	}
+	return rows
+}
+
+func TestWrapColumns(t *testing.T) {
+	rows := WrapColumns([]string{
+		"The quick brown fox jumps over the lazy dog.",
+		"A short one.",
+	}, []int{12, 8}, 2)
+	assert.Equal(t, []string{
+		"The quick     A short",
+		"brown fox     one.",
+		"jumps over",
+		"the lazy",
+		"dog.",
+	}, rows)
+
+	rows = WrapColumns([]string{"ab", "one two three", "日本"}, []int{4, 5, 4}, 1)
+	assert.Equal(t, []string{
+		"ab   one   日本",
+		"     two",
+		"     three",
+	}, rows, "A column which runs out should be padded with spaces.")
+
+	assert.Equal(t, []string{""}, WrapColumns([]string{""}, []int{4}, 1))
+	assert.Empty(t, WrapColumns(nil, nil, 1))
+
+	rows = WrapColumns([]string{"one two", "a b c", "x"}, []int{3}, 1)
+	assert.Equal(t, []string{
+		"one a b c x",
+		"two",
+	}, rows, "Texts without a width should not be wrapped.")
+	assert.Equal(t, []string{"ab cd"}, WrapColumns([]string{"ab", "cd"}, nil, 1))
+}
+
+func TestFitLines(t *testing.T) {
+	const text = "The quick brown fox jumps over the lazy dog."
+	limit := FitLines(text, 3)
+	assert.Equal(t, 15, limit)
+	assert.Len(t, WrapRunes([]rune(text), limit), 3, "The text should fit in 3 lines at the limit.")
+	assert.Len(t, WrapRunes([]rune(text), limit-1), 4, "The text should not fit in 3 lines below the limit.")
+
+	assert.Equal(t, 44, FitLines(text, 1), "A single line should be as wide as the text.")
+	assert.Equal(t, 1, FitLines(text, 100), "Plentiful lines should allow the narrowest limit.")
+	assert.Equal(t, 3, FitLines("abc\ndef\nghi", 2), "Text with too many lines should fit its widest line.")
+	assert.Equal(t, 1, FitLines("", 1))
+	assert.Equal(t, 44, FitLines(text, 0), "At least one line should be allowed.")
+}